@@ -0,0 +1,128 @@
+/*
+Copyright © 2020 Hendry Zhou <hendryzhou889@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/butageek/netool/formatter"
+	"github.com/spf13/cobra"
+)
+
+// exit codes of the diff command, like diff(1)
+const (
+	diffSame    = 0
+	diffChanged = 1
+	diffTrouble = 2
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff [old] [new]",
+	Short: "compare results of two scans",
+	Long: `compare results of two scans written with --stream ndjson
+reports new and vanished hosts, opened and closed ports, MAC or vendor
+changes and banner or version changes
+hosts and ports the new scan didn't cover, or all missing ones if it was
+interrupted, are skipped
+Arguments:
+	old - results of the earlier scan, eg. old.json
+	new - results of the later scan, eg. new.json
+Exits with 0 if nothing changed, 1 if anything changed and 2 on errors`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+		if output != "table" && output != "json" {
+			fmt.Printf("Unsupported output %q, use table or json\n", output)
+			os.Exit(diffTrouble)
+		}
+
+		var snapshots []*formatter.Snapshot
+		for _, path := range args {
+			snapshot, err := readSnapshot(path)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(diffTrouble)
+			}
+			snapshots = append(snapshots, snapshot)
+		}
+		before, after := snapshots[0].Summary, snapshots[1].Summary
+		if before != nil && after != nil && before.Command != after.Command {
+			fmt.Printf("Can't compare %s scan with %s scan\n", before.Command, after.Command)
+			os.Exit(diffTrouble)
+		}
+
+		changes := formatter.Diff(snapshots[0], snapshots[1])
+		if output == "json" {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(changes); err != nil {
+				fmt.Println(err)
+				os.Exit(diffTrouble)
+			}
+		} else if len(changes) == 0 {
+			fmt.Println("No changes")
+		} else {
+			formatter := &formatter.Formatter{
+				Header:          []string{"Change", "Host", "Port", "Old", "New"},
+				Border:          false,
+				Separator:       " ",
+				ColumnSeparator: " ",
+			}
+			formatter.AssembleDiffData(changes)
+			formatter.Print()
+		}
+
+		if len(changes) > 0 {
+			os.Exit(diffChanged)
+		}
+		os.Exit(diffSame)
+	},
+}
+
+// readSnapshot reads scan results of file at path
+// Partial results of interrupted scans are used with a warning
+func readSnapshot(path string) (*formatter.Snapshot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	snapshot, err := formatter.ReadSnapshot(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if snapshot.Summary == nil || snapshot.Summary.Interrupted {
+		log.Printf("%s: scan did not complete, changes may be missing or spurious", path)
+	}
+
+	return snapshot, nil
+}
+
+func init() {
+	diffCmd.Flags().StringP("output", "o", "table", "output format, table or json")
+	rootCmd.AddCommand(diffCmd)
+}
@@ -23,8 +23,11 @@ package cmd
 
 import (
 	"fmt"
+	"log"
+	"sort"
 
 	"github.com/butageek/netool/digger"
+	"github.com/butageek/netool/formatter"
 	"github.com/butageek/netool/validator"
 	"github.com/spf13/cobra"
 )
@@ -43,11 +46,37 @@ Arguments:
 		if !validator.IsValid(v.Regex["domain"], args[0]) {
 			fmt.Println("Invalid domain format")
 			cmd.Help()
+			return
 		}
 
+		ctx, cancel := signalContext()
+		defer cancel()
+
 		myDigger := &digger.Digger{}
 		myDigger.Domain = args[0]
-		myDigger.Dig()
+		records, err := myDigger.Dig(ctx)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		// show what was found, along with the lookups that failed
+		failed := make([]string, 0, len(records.Errors))
+		for recordType := range records.Errors {
+			failed = append(failed, recordType)
+		}
+		sort.Strings(failed)
+		for _, recordType := range failed {
+			log.Printf("%s lookup failed: %v\n", recordType, records.Errors[recordType])
+		}
+
+		formatter := &formatter.Formatter{
+			Header:          []string{"Domain", "Type", "TTL", "Priority", "Value"},
+			Border:          false,
+			Separator:       " ",
+			ColumnSeparator: " ",
+		}
+		formatter.AssembleDigData(records)
+		formatter.Print()
 	},
 }
 
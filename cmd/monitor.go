@@ -0,0 +1,138 @@
+/*
+Copyright © 2020 Hendry Zhou <hendryzhou889@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/butageek/netool/monitor"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// monitorCmd represents the monitor command
+var monitorCmd = &cobra.Command{
+	Use:   "monitor --config monitor.yaml",
+	Short: "scan networks, ports and domains on schedule and report changes",
+	Long: `scan networks, ports and domains on schedule and report changes
+runs in the foreground and logs JSON lines to stderr, changes of hosts,
+ports or DNS records between runs of a job are logged as "change detected"
+SIGHUP reloads the config, SIGINT or SIGTERM stops the monitor
+Config:
+	jobs:
+	  - name: office        # unique name of the job
+	    type: net           # net, port or dig
+	    targets: [192.168.1.0/24]
+	    interval: 10m
+	    arp: true
+	  - name: servers
+	    type: port
+	    targets: [10.0.0.5, 10.0.0.10-20]
+	    ports: 22,80,443    # 1-1023,3389 if omitted
+	    interval: 1h
+	    banner: true
+	    service: true
+	  - name: domains
+	    type: dig
+	    targets: [example.com]
+	    interval: 5m
+	all jobs take exclude and rate, port jobs take udp too and dig jobs
+	take server, eg. 9.9.9.9:53`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if cfgFile == "" {
+			fmt.Println("monitor needs a config file, use --config")
+			return
+		}
+		// resolve path now, the working directory changes to the executable
+		// directory when the port reference is loaded
+		path, err := filepath.Abs(cfgFile)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		viper.SetConfigFile(path)
+		config, err := monitorConfig()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		logger := monitor.NewLogger(os.Stderr)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		reloads := make(chan *monitor.Config)
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+		go func() {
+			for sig := range signals {
+				if sig != syscall.SIGHUP {
+					logger.Info("stopping, waiting for scans in flight", monitor.Fields{"signal": sig.String()})
+					// a second signal terminates the program as usual
+					signal.Stop(signals)
+					cancel()
+					return
+				}
+
+				config, err := monitorConfig()
+				if err != nil {
+					logger.Error("reloading config failed, keeping current config", monitor.Fields{"error": err})
+					continue
+				}
+				select {
+				case reloads <- config:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		monitor.New(logger).Run(ctx, config, reloads)
+	},
+}
+
+// monitorConfig reads and checks the monitor config file
+func monitorConfig() (*monitor.Config, error) {
+	if err := viper.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	var config monitor.Config
+	if err := viper.Unmarshal(&config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", viper.ConfigFileUsed(), err)
+	}
+
+	return &config, nil
+}
+
+func init() {
+	rootCmd.AddCommand(monitorCmd)
+}
@@ -23,8 +23,11 @@ package cmd
 
 import (
 	"fmt"
+	"log"
 
+	"github.com/butageek/netool/formatter"
 	"github.com/butageek/netool/scanner"
+	"github.com/butageek/netool/target"
 	"github.com/butageek/netool/validator"
 	"github.com/spf13/cobra"
 )
@@ -35,22 +38,147 @@ var netCmd = &cobra.Command{
 	Short: "scan network for hosts that are alive",
 	Long: `scan network for hosts that are alive
 Arguments:
-	CIDR - CIDR notation. eg. 192.168.1.1/24`,
+	CIDR - CIDR notation, /16 or /112 at most. eg. 192.168.1.1/24 or fd00::/120`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		// validate argument against CIDR format
 		v := validator.InitValidator()
-		if !validator.IsValid(v.Regex["cidr"], args[0]) {
+		if !validator.IsValid(v.Regex["cidr"], args[0]) && !validator.IsValid(v.Regex["cidr6"], args[0]) {
 			fmt.Println("Invalid CIDR format")
 			cmd.Help()
+			return
 		}
 
-		myScanner := &scanner.Scanner{}
-		myScanner.ScanNet(args[0])
+		// pick discovery probes besides ICMP echo
+		probeTCP, probeUDP, err := discoveryPorts(cmd)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		excludeSpecs, err := exclusionSpecs(cmd)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		exclude, err := target.ParseExclusions(excludeSpecs)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		limit, err := rateLimiter(cmd)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		state, err := checkpoint(cmd)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		stream, err := outputStream(cmd)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		// keep stdout clean for streamed results
+		if stream == nil {
+			fmt.Println()
+		}
+		log.Printf("Scanning net %s\n", args[0])
+		if stream == nil {
+			fmt.Println()
+		}
+
+		ctx, cancel := signalContext()
+		defer cancel()
+
+		arp, _ := cmd.Flags().GetBool("arp")
+		myScanner := &scanner.Scanner{
+			ARP:        arp,
+			ProbeTCP:   probeTCP,
+			ProbeUDP:   probeUDP,
+			Exclude:    exclude,
+			Limiter:    limit,
+			Checkpoint: state,
+			Progress:   &scanner.Progress{},
+		}
+		if stream != nil {
+			myScanner.OnHost = stream.Host
+		}
+		stopProgress := startProgress(myScanner.Progress, "hosts up")
+		hostsAlive, err := myScanner.ScanNet(ctx, args[0])
+		stopProgress()
+		if err != nil {
+			if err != ctx.Err() {
+				fmt.Println(err)
+				return
+			}
+			// show partial results of interrupted scan
+			log.Println("Scan interrupted, showing partial results")
+		}
+		if skipped := myScanner.Stats().Skipped; skipped > 0 {
+			log.Printf("Skipped %d excluded addresses\n", skipped)
+		}
+		if stream != nil {
+			scope := &formatter.Scope{Targets: args, Exclude: excludeSpecs}
+			if err := stream.Summary("net", scope, myScanner.Stats(), ctx.Err() != nil); err != nil {
+				log.Println(err)
+			}
+			return
+		}
+
+		if len(hostsAlive) == 0 {
+			log.Println("No host alive found!")
+			return
+		}
+
+		formatter := &formatter.Formatter{
+			Header:          []string{"Host", "Status", "Hostname", "MAC Address", "Manufacturer", "RTT", "Method"},
+			Border:          false,
+			Separator:       " ",
+			ColumnSeparator: " ",
+		}
+		formatter.AssembleNetData(hostsAlive)
+		formatter.Print()
 	},
 }
 
+// discoveryPorts returns TCP and UDP ports to probe for host discovery
+func discoveryPorts(cmd *cobra.Command) ([]int, []int, error) {
+	var probeTCP, probeUDP []int
+
+	if probe, _ := cmd.Flags().GetBool("probe"); probe {
+		probeTCP = scanner.DefaultProbeTCP
+		probeUDP = scanner.DefaultProbeUDP
+	}
+	if portStr, _ := cmd.Flags().GetString("probe-tcp"); portStr != "" {
+		ports, err := scanner.ParsePorts(portStr)
+		if err != nil {
+			return nil, nil, err
+		}
+		probeTCP = ports
+	}
+	if portStr, _ := cmd.Flags().GetString("probe-udp"); portStr != "" {
+		ports, err := scanner.ParsePorts(portStr)
+		if err != nil {
+			return nil, nil, err
+		}
+		probeUDP = ports
+	}
+
+	return probeTCP, probeUDP, nil
+}
+
 func init() {
+	netCmd.Flags().Bool("arp", false, "discover hosts with ARP requests, for directly attached networks only")
+	netCmd.Flags().Bool("probe", false, "also probe TCP ports 22,80,443,3389 and UDP ports 53,161 to find hosts dropping ICMP")
+	netCmd.Flags().String("probe-tcp", "", "TCP ports to probe for host discovery, eg. 22,80,443")
+	netCmd.Flags().String("probe-udp", "", "UDP ports to probe for host discovery, eg. 53,161")
+	addExcludeFlags(netCmd)
+	addRateFlags(netCmd)
+	addStateFlags(netCmd)
+	addStreamFlag(netCmd)
 	rootCmd.AddCommand(netCmd)
 
 	// Here you will define your flags and configuration settings.
@@ -23,36 +23,326 @@ package cmd
 
 import (
 	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/butageek/netool/formatter"
+	"github.com/butageek/netool/reference"
 	"github.com/butageek/netool/scanner"
+	"github.com/butageek/netool/service"
+	"github.com/butageek/netool/target"
 	"github.com/butageek/netool/validator"
 	"github.com/spf13/cobra"
 )
 
 // portCmd represents the port command
 var portCmd = &cobra.Command{
-	Use:   "port [host]",
-	Short: "scan open ports for the host",
-	Long: `scan open ports for the host
+	Use:   "port [target...]",
+	Short: "scan open ports for the hosts",
+	Long: `scan open ports for the hosts
 Arguments:
-	host - host name or IP address. eg. example.com or 10.1.1.1`,
-	Args: cobra.ExactArgs(1),
+	target - host name, IP address, CIDR or range.
+	         eg. example.com, 10.1.1.1, 2001:db8::1, 10.1.1.0/24 or 10.1.1.1-50`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// validate flag port against port format
 		portStr, _ := cmd.Flags().GetString("port")
+		if n, _ := cmd.Flags().GetInt("top-ports"); n > 0 {
+			if cmd.Flags().Changed("port") {
+				fmt.Println("Use either --port or --top-ports")
+				return
+			}
+			var err error
+			portStr, err = topPorts(cmd, n)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+		}
 		v := validator.InitValidator()
 		if !validator.IsValid(v.Regex["port"], portStr) {
 			fmt.Println("Invalid port format")
 			cmd.Help()
+			return
+		}
+
+		// collect targets from arguments and target file
+		specs := args
+		if targetFile, _ := cmd.Flags().GetString("iL"); targetFile != "" {
+			fileSpecs, err := target.ReadFile(targetFile)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			specs = append(specs, fileSpecs...)
+		}
+		if len(specs) == 0 {
+			fmt.Println("No target given")
+			cmd.Help()
+			return
+		}
+		hosts, err := target.Parse(specs)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		excludeSpecs, err := exclusionSpecs(cmd)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		exclude, err := target.ParseExclusions(excludeSpecs)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		limit, err := rateLimiter(cmd)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		state, err := checkpoint(cmd)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		stream, err := outputStream(cmd)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		initialRTTTimeout, _ := cmd.Flags().GetDuration("initial-rtt-timeout")
+		minRTTTimeout, _ := cmd.Flags().GetDuration("min-rtt-timeout")
+		maxRTTTimeout, _ := cmd.Flags().GetDuration("max-rtt-timeout")
+		if initialRTTTimeout <= 0 || minRTTTimeout <= 0 || maxRTTTimeout < minRTTTimeout {
+			fmt.Println("RTT timeouts must be positive with --min-rtt-timeout not above --max-rtt-timeout")
+			return
+		}
+
+		// keep stdout clean for streamed results
+		if stream == nil {
+			fmt.Println()
+		}
+		if len(hosts) == 1 {
+			log.Printf("Scanning host %s\n", hosts[0])
+		} else {
+			log.Printf("Scanning %d hosts\n", len(hosts))
+		}
+		if stream == nil {
+			fmt.Println()
+		}
+
+		ctx, cancel := signalContext()
+		defer cancel()
+
+		udp, _ := cmd.Flags().GetBool("udp")
+		syn, _ := cmd.Flags().GetBool("syn")
+		if syn && !udp && !scanner.SYNAvailable() {
+			log.Println("SYN scan needs raw socket privileges, falling back to connect scan")
+		}
+
+		banner, _ := cmd.Flags().GetBool("banner")
+		nudge, err := bannerNudge(cmd)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		showClosed, _ := cmd.Flags().GetBool("show-closed")
+		showFiltered, _ := cmd.Flags().GetBool("show-filtered")
+		inspectTLS, _ := cmd.Flags().GetBool("tls")
+		fetchHTTP, _ := cmd.Flags().GetBool("http")
+		maxRedirects, _ := cmd.Flags().GetInt("max-redirects")
+		detect, _ := cmd.Flags().GetBool("service")
+		var services *service.DB
+		if detect {
+			services, err = serviceDB(cmd)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+		}
+
+		myScanner := &scanner.Scanner{
+			UDP:               udp,
+			SYN:               syn,
+			Exclude:           exclude,
+			Banner:            banner,
+			Nudge:             nudge,
+			Services:          services,
+			TLS:               inspectTLS,
+			HTTP:              fetchHTTP,
+			MaxRedirects:      maxRedirects,
+			ShowClosed:        showClosed,
+			ShowFiltered:      showFiltered,
+			Limiter:           limit,
+			InitialRTTTimeout: initialRTTTimeout,
+			MinRTTTimeout:     minRTTTimeout,
+			MaxRTTTimeout:     maxRTTTimeout,
+			Checkpoint:        state,
+			Progress:          &scanner.Progress{},
+		}
+
+		// init port reference object for service names and descriptions
+		portRefArray := reference.PortRefArray{}
+		portRefArray.Init()
+		if stream != nil {
+			myScanner.OnPort = stream.Port
+		}
+		stopProgress := startProgress(myScanner.Progress, "open ports")
+		results, err := myScanner.ScanPort(ctx, hosts, portStr)
+		stopProgress()
+		if err != nil {
+			if err != ctx.Err() {
+				fmt.Println(err)
+				return
+			}
+			// show partial results of interrupted scan
+			log.Println("Scan interrupted, showing partial results")
+		}
+		stats := myScanner.Stats()
+		if stats.Skipped > 0 {
+			log.Printf("Skipped %d excluded addresses\n", stats.Skipped)
+		}
+		logHiddenPorts(stats, showClosed, showFiltered)
+		if stream != nil {
+			scope := &formatter.Scope{Targets: specs, Exclude: excludeSpecs, Ports: portStr, Protocol: "tcp"}
+			if udp {
+				scope.Protocol = "udp"
+			}
+			if err := stream.Summary("port", scope, stats, ctx.Err() != nil); err != nil {
+				log.Println(err)
+			}
+			return
 		}
 
-		myScanner := &scanner.Scanner{}
-		myScanner.ScanPort(args[0], portStr)
+		if len(results) == 0 {
+			log.Println("No open ports found!")
+			return
+		}
+
+		header := []string{"Host", "Port", "Protocol", "State"}
+		if showClosed || showFiltered {
+			header = append(header, "Reason")
+		}
+		header = append(header, "Service Name")
+		if banner {
+			header = append(header, "Banner")
+		}
+		if detect {
+			header = append(header, "Product", "Version", "CPE")
+		}
+		if inspectTLS {
+			header = append(header, "TLS", "Certificate", "Expires", "TLS Problems")
+		}
+		if fetchHTTP {
+			header = append(header, "HTTP Status", "Server", "Title", "Content Length", "Tech")
+		}
+		header = append(header, "Description", "Latency")
+
+		expiryDays, _ := cmd.Flags().GetInt("expiry-warn")
+		formatter := &formatter.Formatter{
+			Header:          header,
+			Border:          false,
+			Separator:       " ",
+			ColumnSeparator: " ",
+			ExpiryDays:      expiryDays,
+		}
+		formatter.AssemblePortData(results, &portRefArray)
+		formatter.Print()
 	},
 }
 
+// topPorts returns the n most common ports of the scanned protocol as port
+// argument
+func topPorts(cmd *cobra.Command, n int) (string, error) {
+	protocol := "tcp"
+	if udp, _ := cmd.Flags().GetBool("udp"); udp {
+		protocol = "udp"
+	}
+	ports, err := reference.TopPorts(n, protocol)
+	if err != nil {
+		return "", err
+	}
+
+	portStrs := make([]string, len(ports))
+	for i, port := range ports {
+		portStrs[i] = strconv.Itoa(port)
+	}
+
+	return strings.Join(portStrs, ","), nil
+}
+
+// logHiddenPorts logs numbers of ports left out of the results
+func logHiddenPorts(stats scanner.Stats, showClosed, showFiltered bool) {
+	var hidden []string
+	if !showClosed && stats.Closed > 0 {
+		hidden = append(hidden, fmt.Sprintf("%d closed", stats.Closed))
+	}
+	if !showFiltered && stats.Filtered > 0 {
+		hidden = append(hidden, fmt.Sprintf("%d filtered", stats.Filtered))
+	}
+	if len(hidden) > 0 {
+		log.Printf("Not shown: %s ports\n", strings.Join(hidden, ", "))
+	}
+	if stats.Errors > 0 {
+		log.Printf("Failed to scan %d ports\n", stats.Errors)
+	}
+}
+
+// bannerNudge returns the request sent to silent ports in banner mode
+// escapes such as \r\n are interpreted
+func bannerNudge(cmd *cobra.Command) ([]byte, error) {
+	nudgeStr, _ := cmd.Flags().GetString("banner-nudge")
+	nudge, err := strconv.Unquote(`"` + strings.Replace(nudgeStr, `"`, `\"`, -1) + `"`)
+	if err != nil {
+		return nil, fmt.Errorf("invalid banner nudge %q", nudgeStr)
+	}
+
+	return []byte(nudge), nil
+}
+
+// serviceDB loads the shipped service probes and the custom probe files
+// given by flag
+func serviceDB(cmd *cobra.Command) (*service.DB, error) {
+	paths, _ := cmd.Flags().GetStringSlice("service-probes")
+	// resolve paths now, the working directory changes to the executable
+	// directory when the port reference is loaded
+	for i, path := range paths {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		paths[i] = absPath
+	}
+
+	return service.LoadDefault(paths...)
+}
+
 func init() {
 	portCmd.Flags().StringP("port", "p", "1-1023,3389", "port number to scan, eg. 80,100-200")
+	portCmd.Flags().Int("top-ports", 0, "scan the n best ranked ports of port-frequency.csv instead of --port")
+	portCmd.Flags().BoolP("udp", "u", false, "scan UDP ports instead of TCP")
+	portCmd.Flags().BoolP("syn", "s", false, "use half-open SYN scan, needs raw socket privileges")
+	portCmd.Flags().Bool("show-closed", false, "also show closed ports")
+	portCmd.Flags().Bool("show-filtered", false, "also show filtered and open|filtered ports")
+	portCmd.Flags().BoolP("banner", "b", false, "read banners of open TCP ports")
+	portCmd.Flags().String("banner-nudge", strings.Trim(strconv.Quote(string(scanner.DefaultNudge)), `"`), "request sent to ports not sending a banner, empty to disable")
+	portCmd.Flags().BoolP("service", "V", false, "detect service, product and version of open TCP ports")
+	portCmd.Flags().StringSlice("service-probes", nil, "additional service probe files, eg. custom-probes.txt")
+	portCmd.Flags().Bool("tls", false, "inspect TLS certificates of open TCP ports")
+	addExpiryFlag(portCmd)
+	portCmd.Flags().Bool("http", false, "fingerprint web servers on open TCP ports")
+	portCmd.Flags().Int("max-redirects", 3, "redirects followed when fingerprinting web servers")
+	addExcludeFlags(portCmd)
+	addRateFlags(portCmd)
+	addStateFlags(portCmd)
+	addStreamFlag(portCmd)
+	portCmd.Flags().Duration("initial-rtt-timeout", scanner.DefaultInitialRTTTimeout, "TCP connect timeout of hosts until their round trip time is measured, UDP and SYN probes wait fixed times")
+	portCmd.Flags().Duration("min-rtt-timeout", scanner.DefaultMinRTTTimeout, "lower bound of connect timeouts adapted to round trip times")
+	portCmd.Flags().Duration("max-rtt-timeout", scanner.DefaultMaxRTTTimeout, "upper bound of connect timeouts adapted to round trip times")
+	portCmd.Flags().String("iL", "", "read targets from file, separated by lines, spaces or commas, # starts a comment")
 	rootCmd.AddCommand(portCmd)
 
 	// Here you will define your flags and configuration settings.
@@ -0,0 +1,126 @@
+/*
+Copyright © 2020 Hendry Zhou <hendryzhou889@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/butageek/netool/scanner"
+)
+
+// progress intervals on a terminal and in logs
+const (
+	progressTTYInterval = 250 * time.Millisecond
+	progressLogInterval = 10 * time.Second
+)
+
+// rateWindow time the current probe rate is averaged over
+const rateWindow = 5 * time.Second
+
+// startProgress reports progress of a scan on stderr until the returned
+// function is called. The line is updated in place on a terminal and
+// logged periodically otherwise
+func startProgress(progress *scanner.Progress, hitName string) func() {
+	tty := isTerminal(os.Stderr)
+	interval := progressLogInterval
+	if tty {
+		interval = progressTTYInterval
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		start := time.Now()
+		// probes done at times within rateWindow for the current rate
+		type sample struct {
+			at   time.Time
+			done int
+		}
+		samples := []sample{{at: start}}
+		for {
+			select {
+			case <-done:
+				if tty {
+					// clear the line for the results
+					fmt.Fprint(os.Stderr, "\r\033[K")
+				}
+				return
+			case now := <-ticker.C:
+				numDone, total, hits := progress.Counts()
+				samples = append(samples, sample{at: now, done: numDone})
+				for len(samples) > 2 && now.Sub(samples[1].at) >= rateWindow {
+					samples = samples[1:]
+				}
+				oldest := samples[0]
+				rate := float64(numDone-oldest.done) / now.Sub(oldest.at).Seconds()
+
+				line := progressLine(numDone, total, hits, hitName, rate, now.Sub(start))
+				if tty {
+					fmt.Fprint(os.Stderr, "\r\033[K"+line)
+				} else {
+					log.Println(line)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// progressLine formats progress, the ETA is based on the average rate since
+// the scan started
+func progressLine(done, total, hits int, hitName string, rate float64, elapsed time.Duration) string {
+	percent := 0.0
+	if total > 0 {
+		percent = float64(done) * 100 / float64(total)
+	}
+	eta := "--"
+	if done > 0 && done < total {
+		remaining := time.Duration(float64(elapsed) / float64(done) * float64(total-done))
+		eta = remaining.Round(time.Second).String()
+	} else if done >= total {
+		eta = "0s"
+	}
+
+	return fmt.Sprintf("Probes %d/%d (%.1f%%), %.1f probes/s, ETA %s, %d %s",
+		done, total, percent, rate, eta, hits, hitName)
+}
+
+// isTerminal reports whether f is a terminal
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice != 0
+}
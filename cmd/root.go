@@ -22,9 +22,19 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
 
+	"github.com/butageek/netool/formatter"
+	"github.com/butageek/netool/limiter"
+	"github.com/butageek/netool/scanner"
+	"github.com/butageek/netool/target"
 	"github.com/spf13/cobra"
 
 	homedir "github.com/mitchellh/go-homedir"
@@ -46,12 +56,28 @@ var rootCmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	rootCmd.SetArgs(nmapArgs(os.Args[1:]))
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
 
+// nmapArgs rewrites nmap style -iL to --iL, which pflag would read as
+// shorthand -i
+func nmapArgs(args []string) []string {
+	rewritten := make([]string, len(args))
+
+	for i, arg := range args {
+		if arg == "-iL" || strings.HasPrefix(arg, "-iL=") {
+			arg = "-" + arg
+		}
+		rewritten[i] = arg
+	}
+
+	return rewritten
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 
@@ -91,3 +117,181 @@ func initConfig() {
 		fmt.Println("Using config file:", viper.ConfigFileUsed())
 	}
 }
+
+// signalContext returns a context that is canceled on the first SIGINT
+// A second SIGINT terminates the program as usual
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt)
+	go func() {
+		select {
+		case <-sigChan:
+			log.Println("Interrupted, waiting for probes in flight...")
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(sigChan)
+	}()
+
+	return ctx, cancel
+}
+
+// exclusionSpecs reads specs of excluded targets from --exclude and
+// --exclude-file flags
+func exclusionSpecs(cmd *cobra.Command) ([]string, error) {
+	specs, _ := cmd.Flags().GetStringSlice("exclude")
+	if excludeFile, _ := cmd.Flags().GetString("exclude-file"); excludeFile != "" {
+		fileSpecs, err := target.ReadFile(excludeFile)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, fileSpecs...)
+	}
+
+	return specs, nil
+}
+
+// addExcludeFlags adds flags for excluding targets to cmd
+func addExcludeFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("exclude", nil, "IPs, CIDRs or ranges never to scan, eg. 10.0.0.5,10.0.1.0/28")
+	cmd.Flags().String("exclude-file", "", "read IPs, CIDRs or ranges never to scan from file")
+}
+
+// addExpiryFlag adds flag for flagging certificates about to expire to cmd
+func addExpiryFlag(cmd *cobra.Command) {
+	cmd.Flags().Int("expiry-warn", 30, "flag certificates expiring within this many days")
+}
+
+// rateLimiter builds the probe rate limiter from --rate, --burst and
+// --max-rate flags, returns nil if no rate is limited
+func rateLimiter(cmd *cobra.Command) (*limiter.Limiter, error) {
+	rate, _ := cmd.Flags().GetFloat64("rate")
+	maxRate, _ := cmd.Flags().GetFloat64("max-rate")
+	burst, _ := cmd.Flags().GetInt("burst")
+	if rate < 0 || maxRate < 0 || burst < 0 {
+		return nil, errors.New("rates and burst can't be negative")
+	}
+	if rate == 0 && maxRate == 0 {
+		return nil, nil
+	}
+
+	return limiter.New(rate, burst, maxRate), nil
+}
+
+// addRateFlags adds flags for limiting the probe rate to cmd
+func addRateFlags(cmd *cobra.Command) {
+	cmd.Flags().Float64("rate", 0, "probes sent per second on average, unlimited if 0")
+	cmd.Flags().Int("burst", 1, "probes sent at once after idle periods when --rate is set")
+	cmd.Flags().Float64("max-rate", 0, "probes sent per second at most, even in bursts, unlimited if 0")
+}
+
+// checkpoint returns the checkpoint to save scan progress to by
+// --state-file, loaded from the file if --resume is set
+// returns nil if no state file is given
+func checkpoint(cmd *cobra.Command) (*scanner.Checkpoint, error) {
+	stateFile, _ := cmd.Flags().GetString("state-file")
+	resume, _ := cmd.Flags().GetBool("resume")
+	if stateFile == "" {
+		if resume {
+			return nil, errors.New("--resume needs --state-file")
+		}
+		return nil, nil
+	}
+
+	// resolve path now, the working directory changes to the executable
+	// directory when the port reference is loaded
+	stateFile, err := filepath.Abs(stateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	args := scanArgs(os.Args[1:])
+	if !resume {
+		return scanner.NewCheckpoint(stateFile, args), nil
+	}
+
+	c, err := scanner.LoadCheckpoint(stateFile)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Join(c.Args, "\x00") != strings.Join(args, "\x00") {
+		return nil, fmt.Errorf("state file %s belongs to another scan: netool %s", stateFile, strings.Join(c.Args, " "))
+	}
+	if c.Complete() {
+		log.Println("Scan in state file is complete already, showing its results")
+	} else {
+		log.Printf("Resuming scan from %s\n", stateFile)
+	}
+
+	return c, nil
+}
+
+// pacingFlags flags that may change when a scan is resumed, as they don't
+// change what is scanned
+// The state file is named by the resumed scan anyway, in any spelling of
+// its path
+var pacingFlags = map[string]bool{
+	"resume":              false,
+	"state-file":          true,
+	"rate":                true,
+	"burst":               true,
+	"max-rate":            true,
+	"initial-rtt-timeout": true,
+	"min-rtt-timeout":     true,
+	"max-rtt-timeout":     true,
+}
+
+// scanArgs returns the arguments identifying a scan, which are all but
+// pacingFlags and their values
+func scanArgs(args []string) []string {
+	var identity []string
+
+	for i := 0; i < len(args); i++ {
+		name := strings.TrimPrefix(args[i], "--")
+		if name == args[i] {
+			identity = append(identity, args[i])
+			continue
+		}
+		if eq := strings.Index(name, "="); eq >= 0 {
+			if _, ok := pacingFlags[name[:eq]]; ok {
+				continue
+			}
+		} else if takesValue, ok := pacingFlags[name]; ok {
+			// skip the value given as separate argument
+			if takesValue {
+				i++
+			}
+			continue
+		}
+		identity = append(identity, args[i])
+	}
+
+	return identity
+}
+
+// addStateFlags adds flags for saving and resuming scans to cmd
+func addStateFlags(cmd *cobra.Command) {
+	cmd.Flags().String("state-file", "", "save progress of the scan to file to resume it later")
+	cmd.Flags().Bool("resume", false, "resume the scan saved to --state-file, with the same arguments")
+}
+
+// outputStream returns the stream results are written to by --stream, or
+// nil if results are printed as table at the end
+func outputStream(cmd *cobra.Command) (*formatter.Stream, error) {
+	format, _ := cmd.Flags().GetString("stream")
+	switch format {
+	case "":
+		return nil, nil
+	case "ndjson":
+		return formatter.NewStream(os.Stdout), nil
+	}
+
+	return nil, fmt.Errorf("unknown stream format %q, supported: ndjson", format)
+}
+
+// addStreamFlag adds flag for streaming results to cmd
+func addStreamFlag(cmd *cobra.Command) {
+	cmd.Flags().String("stream", "", "write results to stdout as they are found instead of a table, format: ndjson")
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/butageek/netool/scanner"
+	"github.com/spf13/cobra"
+)
+
+func TestScanArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "no pacing flags",
+			args: []string{"port", "10.0.0.1", "-p", "1-100"},
+			want: []string{"port", "10.0.0.1", "-p", "1-100"},
+		},
+		{
+			name: "flag with separate value",
+			args: []string{"port", "10.0.0.1", "--rate", "100", "-p", "22"},
+			want: []string{"port", "10.0.0.1", "-p", "22"},
+		},
+		{
+			name: "flag with value after equals sign",
+			args: []string{"port", "10.0.0.1", "--rate=100", "-p", "22"},
+			want: []string{"port", "10.0.0.1", "-p", "22"},
+		},
+		{
+			name: "flag without value",
+			args: []string{"port", "10.0.0.1", "--resume", "-p", "22"},
+			want: []string{"port", "10.0.0.1", "-p", "22"},
+		},
+		{
+			name: "state file in any spelling",
+			args: []string{"port", "10.0.0.1", "--state-file", "./st.json", "--resume"},
+			want: []string{"port", "10.0.0.1"},
+		},
+		{
+			name: "state file after equals sign",
+			args: []string{"port", "10.0.0.1", "--state-file=st.json"},
+			want: []string{"port", "10.0.0.1"},
+		},
+		{
+			name: "other flags are kept",
+			args: []string{"port", "10.0.0.1", "--show-closed", "--top-ports=100"},
+			want: []string{"port", "10.0.0.1", "--show-closed", "--top-ports=100"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := scanArgs(tt.args)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("scanArgs(%q) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckpointResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+	if err := scanner.NewCheckpoint(path, []string{"port", "10.0.0.1", "-p", "22"}).Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	args := os.Args
+	defer func() { os.Args = args }()
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{name: "same scan", args: []string{"port", "10.0.0.1", "-p", "22", "--rate", "100"}},
+		{name: "other target", args: []string{"port", "10.0.0.2", "-p", "22"}, wantErr: true},
+		{name: "other ports", args: []string{"port", "10.0.0.1", "-p", "80"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			addStateFlags(cmd)
+			cmd.Flags().Set("state-file", path)
+			cmd.Flags().Set("resume", "true")
+			os.Args = append(append([]string{"netool"}, tt.args...), "--state-file", path, "--resume")
+
+			c, err := checkpoint(cmd)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkpoint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && c.Path != path {
+				t.Errorf("checkpoint() path = %s, want %s", c.Path, path)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+/*
+Copyright © 2020 Hendry Zhou <hendryzhou889@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/butageek/netool/formatter"
+	"github.com/butageek/netool/inspector"
+	"github.com/spf13/cobra"
+)
+
+// tlsCmd represents the tls command
+var tlsCmd = &cobra.Command{
+	Use:   "tls [host:port...]",
+	Short: "inspect TLS certificates of services",
+	Long: `inspect TLS certificates of services
+Arguments:
+	host:port - host name or IP address with port, 443 if omitted.
+	            eg. example.com, example.com:8443 or [2001:db8::1]:443`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sni, _ := cmd.Flags().GetString("sni")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		myInspector := &inspector.Inspector{
+			ServerName: sni,
+			Timeout:    timeout,
+		}
+
+		var reports []*inspector.Report
+		for _, arg := range args {
+			host, port, err := splitHostPort(arg, 443)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			report, err := myInspector.Inspect(host, port)
+			if err != nil {
+				fmt.Printf("%s: %v\n", arg, err)
+				continue
+			}
+			reports = append(reports, report)
+		}
+		if len(reports) == 0 {
+			return
+		}
+
+		expiryDays, _ := cmd.Flags().GetInt("expiry-warn")
+		formatter := &formatter.Formatter{
+			Header:          []string{"Field", "Value"},
+			Border:          false,
+			Separator:       " ",
+			ColumnSeparator: " ",
+			ExpiryDays:      expiryDays,
+		}
+		formatter.AssembleTLSData(reports)
+		formatter.Print()
+	},
+}
+
+// splitHostPort splits host:port, using defaultPort if there's no port
+func splitHostPort(hostPort string, defaultPort int) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		// no port given, strip brackets of IPv6 addresses
+		host = hostPort
+		if len(host) > 1 && host[0] == '[' && host[len(host)-1] == ']' {
+			host = host[1 : len(host)-1]
+		}
+		return host, defaultPort, nil
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid port %q", portStr)
+	}
+
+	return host, port, nil
+}
+
+func init() {
+	tlsCmd.Flags().String("sni", "", "server name sent and checked against certificates, the host by default")
+	tlsCmd.Flags().Duration("timeout", 5*time.Second, "time to wait for the handshake")
+	addExpiryFlag(tlsCmd)
+	rootCmd.AddCommand(tlsCmd)
+}
@@ -1,153 +1,181 @@
 package digger
 
 import (
-	"fmt"
+	"context"
 	"net"
-	"strconv"
+	"time"
 
-	"github.com/butageek/netool/formatter"
+	"golang.org/x/net/dns/dnsmessage"
 )
 
-// Digger struct of Digger
-type Digger struct {
-	Domain string
+// record types looked up by Dig
+const (
+	TypeA     = "A"
+	TypeAAAA  = "AAAA"
+	TypeCNAME = "CNAME"
+	TypeNS    = "NS"
+	TypeMX    = "MX"
+)
+
+// Record typed DNS record
+// Priority is only set for MX records
+type Record struct {
+	Name     string
+	Type     string
+	TTL      uint32
+	Value    string
+	Priority uint16
 }
 
-// Dig looks up information for given domain
-// Includes records of IP, NS, CNAME, MX
-func (d *Digger) Dig() error {
-	// get A records
-	addrs, err := digHost(d.Domain)
-	if err != nil {
-		return err
-	}
-	// get NS records
-	nss, err := digNS(d.Domain)
-	if err != nil {
-		return err
-	}
-	// get CNAME records
-	cname, err := digCNAME(d.Domain)
-	if err != nil {
-		return err
-	}
-	// get MX records
-	mxs, err := digMX(d.Domain)
-	if err != nil {
-		return err
-	}
+// Records records found for a domain
+// Errors holds errors of record types whose lookup failed, records of these
+// types may be missing from Entries
+type Records struct {
+	Domain  string
+	Entries []Record
+	Errors  map[string]error
+}
 
-	formatter := &formatter.Formatter{
-		Header:          []string{"Domain", "Type", "Value"},
-		Data:            assembleDigData(d, addrs, nss, mxs, cname),
-		Border:          false,
-		Separator:       " ",
-		ColumnSeparator: " ",
+// ByType returns records of given type
+func (r *Records) ByType(recordType string) []Record {
+	var records []Record
+
+	for _, record := range r.Entries {
+		if record.Type == recordType {
+			records = append(records, record)
+		}
 	}
-	formatter.Print()
 
-	return nil
+	return records
 }
 
-// assembleDigData aseembles data for Formatter struct
-func assembleDigData(d *Digger, addrs, nss, mxs []string, cname string) [][]string {
-	var data [][]string
+// Digger struct of Digger
+// Server is the DNS server to query as host:port, the system resolver is
+// used if it's empty
+type Digger struct {
+	Domain  string
+	Server  string
+	Timeout time.Duration
+}
 
-	// append A records as rows to data
-	for _, addr := range addrs {
-		row := []string{
-			d.Domain,
-			"A",
-			addr,
-		}
-		data = append(data, row)
-	}
-	data = append(data, []string{"", "", ""})
+// digTypes record types queried by Dig, in output order
+var digTypes = []struct {
+	name  string
+	qtype dnsmessage.Type
+}{
+	{TypeA, dnsmessage.TypeA},
+	{TypeAAAA, dnsmessage.TypeAAAA},
+	{TypeCNAME, dnsmessage.TypeCNAME},
+	{TypeNS, dnsmessage.TypeNS},
+	{TypeMX, dnsmessage.TypeMX},
+}
 
-	// append CNAME records as rows to data
-	row := []string{
-		d.Domain,
-		"CNAME",
-		cname,
+// Dig looks up information for given domain
+// Includes records of A, AAAA, CNAME, NS, MX. Failed lookups of some types
+// are reported in Records.Errors, an error is returned if the domain
+// doesn't exist, all lookups failed or ctx is done
+func (d *Digger) Dig(ctx context.Context) (*Records, error) {
+	timeout := d.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
 	}
-	data = append(data, row)
-	data = append(data, []string{"", "", ""})
 
-	// append NS records as rows to data
-	for _, ns := range nss {
-		row := []string{
-			d.Domain,
-			"NS",
-			ns,
-		}
-		data = append(data, row)
+	server := d.Server
+	if server == "" {
+		server = systemServer()
+	}
+	// fall back to Go resolver if no DNS server is known, TTLs are unknown then
+	if server == "" {
+		return digLocal(ctx, d.Domain)
 	}
-	data = append(data, []string{"", "", ""})
 
-	// append MX records as rows to data
-	for _, mx := range mxs {
-		row := []string{
-			d.Domain,
-			"MX",
-			mx,
+	records := &Records{Domain: d.Domain, Errors: map[string]error{}}
+	var lastErr error
+	for _, t := range digTypes {
+		entries, err := query(ctx, server, d.Domain, t.qtype, timeout)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			if err == errNXDomain {
+				return nil, err
+			}
+			records.Errors[t.name] = err
+			lastErr = err
+			continue
 		}
-		data = append(data, row)
+		records.Entries = append(records.Entries, entries...)
 	}
 
-	return data
-}
-
-// digHost gets A records
-func digHost(host string) ([]string, error) {
-	var addrs []string
-
-	addrs, err := net.LookupHost(host)
-	if err != nil {
-		return nil, err
+	if len(records.Errors) == len(digTypes) {
+		return nil, lastErr
 	}
 
-	return addrs, nil
+	return records, nil
 }
 
-// digNS gets NS records
-func digNS(domain string) ([]string, error) {
-	var nss []string
+// digLocal looks up records using Go resolver
+func digLocal(ctx context.Context, domain string) (*Records, error) {
+	records := &Records{Domain: domain, Errors: map[string]error{}}
+	name := fqdn(domain)
+	resolver := net.DefaultResolver
 
-	ns, err := net.LookupNS(domain)
+	// get A and AAAA records
+	addrs, err := resolver.LookupIPAddr(ctx, domain)
 	if err != nil {
 		return nil, err
 	}
+	for _, addr := range addrs {
+		recordType := TypeA
+		if addr.IP.To4() == nil {
+			recordType = TypeAAAA
+		}
+		records.Entries = append(records.Entries, Record{Name: name, Type: recordType, Value: addr.IP.String()})
+	}
+	// get CNAME record
+	cname, err := resolver.LookupCNAME(ctx, domain)
+	if err == nil && cname != name {
+		records.Entries = append(records.Entries, Record{Name: name, Type: TypeCNAME, Value: cname})
+	}
+	records.addError(TypeCNAME, err)
+	// get NS records
+	nss, err := resolver.LookupNS(ctx, domain)
+	for _, ns := range nss {
+		records.Entries = append(records.Entries, Record{Name: name, Type: TypeNS, Value: ns.Host})
+	}
+	records.addError(TypeNS, err)
+	// get MX records
+	mxs, err := resolver.LookupMX(ctx, domain)
+	for _, mx := range mxs {
+		records.Entries = append(records.Entries, Record{Name: name, Type: TypeMX, Value: mx.Host, Priority: mx.Pref})
+	}
+	records.addError(TypeMX, err)
 
-	for i := 0; i < len(ns); i++ {
-		nss = append(nss, ns[i].Host)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
 	}
 
-	return nss, nil
+	return records, nil
 }
 
-// digCNAME gets CNAME records
-func digCNAME(host string) (string, error) {
-	cname, err := net.LookupCNAME(host)
-	if err != nil {
-		return "", err
+// addError records err of a failed lookup of recordType
+// Lookups finding no records of the type didn't fail
+func (r *Records) addError(recordType string, err error) {
+	if err == nil {
+		return
+	}
+	if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+		return
 	}
 
-	return cname, nil
+	r.Errors[recordType] = err
 }
 
-// digMX gets MX records
-func digMX(domain string) ([]string, error) {
-	var mxs []string
-
-	mx, err := net.LookupMX(domain)
-	if err != nil {
-		return nil, err
-	}
-
-	for i := 0; i < len(mx); i++ {
-		mxStr := fmt.Sprintf("%s %s", mx[i].Host, strconv.Itoa(int(mx[i].Pref)))
-		mxs = append(mxs, mxStr)
+// fqdn appends trailing dot to domain if missing
+func fqdn(domain string) string {
+	if len(domain) > 0 && domain[len(domain)-1] == '.' {
+		return domain
 	}
 
-	return mxs, nil
+	return domain + "."
 }
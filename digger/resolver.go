@@ -0,0 +1,232 @@
+package digger
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var errNXDomain = errors.New("no such domain")
+
+// systemServer returns the first nameserver in /etc/resolv.conf
+// returns empty string if there is none
+func systemServer() string {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			// drop zone of link-local IPv6 nameservers
+			host := strings.SplitN(fields[1], "%", 2)[0]
+			return net.JoinHostPort(host, "53")
+		}
+	}
+
+	return ""
+}
+
+// query sends a query of type qtype for domain to server and returns
+// answers of that type
+func query(ctx context.Context, server, domain string, qtype dnsmessage.Type, timeout time.Duration) ([]Record, error) {
+	name, err := dnsmessage.NewName(fqdn(domain))
+	if err != nil {
+		return nil, err
+	}
+
+	req := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:               queryID(),
+			RecursionDesired: true,
+		},
+		Questions: []dnsmessage.Question{
+			{
+				Name:  name,
+				Type:  qtype,
+				Class: dnsmessage.ClassINET,
+			},
+		},
+	}
+	packed, err := req.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := exchangeUDP(ctx, server, packed, req.ID, timeout)
+	if err != nil {
+		return nil, err
+	}
+	// retry over TCP if answer doesn't fit in UDP
+	if resp.Truncated {
+		resp, err = exchangeTCP(ctx, server, packed, req.ID, timeout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch resp.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, errNXDomain
+	default:
+		return nil, errors.New("DNS query failed: " + resp.RCode.String())
+	}
+
+	return parseAnswers(resp.Answers, name.String(), qtype), nil
+}
+
+// queryID returns a random query ID
+func queryID() uint16 {
+	b := make([]byte, 2)
+	rand.Read(b)
+
+	return binary.BigEndian.Uint16(b)
+}
+
+// exchangeUDP sends packed query over UDP and reads the response with given id
+func exchangeUDP(ctx context.Context, server string, packed []byte, id uint16, timeout time.Duration) (*dnsmessage.Message, error) {
+	conn, err := dial(ctx, "udp", server, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	defer abortOnDone(ctx, conn)()
+
+	if _, err := conn.Write(packed); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 512)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		var resp dnsmessage.Message
+		// skip responses that don't belong to the query
+		if err := resp.Unpack(buf[:n]); err != nil || resp.ID != id || !resp.Response {
+			continue
+		}
+
+		return &resp, nil
+	}
+}
+
+// exchangeTCP sends packed query over TCP and reads the response with given id
+func exchangeTCP(ctx context.Context, server string, packed []byte, id uint16, timeout time.Duration) (*dnsmessage.Message, error) {
+	conn, err := dial(ctx, "tcp", server, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	defer abortOnDone(ctx, conn)()
+
+	// messages over TCP are prefixed with 2 bytes length
+	msg := make([]byte, 2+len(packed))
+	binary.BigEndian.PutUint16(msg, uint16(len(packed)))
+	copy(msg[2:], packed)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+
+	var length uint16
+	if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+
+	var resp dnsmessage.Message
+	if err := resp.Unpack(buf); err != nil {
+		return nil, err
+	}
+	if resp.ID != id {
+		return nil, errors.New("DNS response ID mismatch")
+	}
+
+	return &resp, nil
+}
+
+// dial connects to server with a deadline of timeout for the exchange
+func dial(ctx context.Context, network, server string, timeout time.Duration) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	return conn, nil
+}
+
+// abortOnDone aborts reads and writes on conn once ctx is done, until the
+// returned function is called
+func abortOnDone(ctx context.Context, conn net.Conn) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// parseAnswers converts answers of type qtype for name to records
+// Answers reached through a CNAME chain are labelled with name, like
+// lookups of the Go resolver are
+func parseAnswers(answers []dnsmessage.Resource, name string, qtype dnsmessage.Type) []Record {
+	var records []Record
+
+	for _, answer := range answers {
+		if answer.Header.Type != qtype {
+			continue
+		}
+
+		record := Record{
+			Name: name,
+			TTL:  answer.Header.TTL,
+		}
+		switch body := answer.Body.(type) {
+		case *dnsmessage.AResource:
+			record.Type = TypeA
+			record.Value = net.IP(body.A[:]).String()
+		case *dnsmessage.AAAAResource:
+			record.Type = TypeAAAA
+			record.Value = net.IP(body.AAAA[:]).String()
+		case *dnsmessage.CNAMEResource:
+			record.Type = TypeCNAME
+			record.Value = body.CNAME.String()
+		case *dnsmessage.NSResource:
+			record.Type = TypeNS
+			record.Value = body.NS.String()
+		case *dnsmessage.MXResource:
+			record.Type = TypeMX
+			record.Value = body.MX.String()
+			record.Priority = body.Pref
+		default:
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
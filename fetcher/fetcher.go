@@ -0,0 +1,217 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/tls"
+	"html"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/butageek/netool/limiter"
+)
+
+// maxBodyLen number of bytes of a page read to find its title
+const maxBodyLen = 1 << 20
+
+// titleRegex matches the title element of a HTML page
+var titleRegex = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// Page fingerprint of a web page
+// URL is where the last redirect led to, ContentLength is -1 if unknown
+// Tech lists technologies hinted by response headers and cookies
+type Page struct {
+	URL           string
+	Status        int
+	Server        string
+	Title         string
+	ContentLength int64
+	Tech          []string
+	Redirects     int
+}
+
+// Fetcher struct of Fetcher
+// MaxRedirects is the number of redirects followed, redirects are not
+// followed if it's 0. Redirects to other hosts are never followed, as they
+// may lead to hosts that must not be scanned. Limiter limits the rate of
+// requests if it's set
+type Fetcher struct {
+	Timeout      time.Duration
+	MaxRedirects int
+	Limiter      *limiter.Limiter
+}
+
+// Fetch sends a GET for / to the port of host and fingerprints the page
+// Certificates are not verified, TLS inspection reports them
+func (f *Fetcher) Fetch(ctx context.Context, host string, port int, useTLS bool) (*Page, error) {
+	timeout := f.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
+	}
+	url := scheme + "://" + net.JoinHostPort(host, strconv.Itoa(port)) + "/"
+
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+		},
+		// redirects are followed below, so each request waits for the
+		// limiter without using up the timeout of the next
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	var resp *http.Response
+	redirects := 0
+	for {
+		if err := f.Limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", "netool")
+		resp, err = client.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		// report the redirect exceeding the limit or leaving the host as
+		// the page
+		next, ok := f.redirect(resp, host, redirects)
+		if !ok {
+			break
+		}
+		resp.Body.Close()
+		url = next
+		redirects++
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodyLen))
+	page := &Page{
+		URL:           resp.Request.URL.String(),
+		Status:        resp.StatusCode,
+		Server:        resp.Header.Get("Server"),
+		Title:         title(body),
+		ContentLength: resp.ContentLength,
+		Tech:          techHints(resp),
+		Redirects:     redirects,
+	}
+	// body was read completely, so its length is known
+	if page.ContentLength < 0 && len(body) < maxBodyLen {
+		page.ContentLength = int64(len(body))
+	}
+
+	return page, nil
+}
+
+// redirect returns the URL resp redirects to if it's to be followed after
+// redirects others
+// Only redirects over HTTP or HTTPS to host are followed
+func (f *Fetcher) redirect(resp *http.Response, host string, redirects int) (string, bool) {
+	if redirects >= f.MaxRedirects {
+		return "", false
+	}
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return "", false
+	}
+	location, err := resp.Location()
+	if err != nil {
+		return "", false
+	}
+	if location.Scheme != "http" && location.Scheme != "https" {
+		return "", false
+	}
+	if !strings.EqualFold(location.Hostname(), host) {
+		return "", false
+	}
+
+	return location.String(), true
+}
+
+// title returns the title of a HTML page on one line
+func title(body []byte) string {
+	groups := titleRegex.FindSubmatch(body)
+	if groups == nil {
+		return ""
+	}
+
+	return strings.Join(strings.Fields(html.UnescapeString(string(groups[1]))), " ")
+}
+
+// techHeaders response headers naming the technology behind a site
+var techHeaders = []string{"X-Powered-By", "X-AspNet-Version", "X-AspNetMvc-Version", "X-Generator"}
+
+// techMarkers response headers and cookies that give away a technology
+var techMarkers = []struct {
+	header string
+	cookie string
+	tech   string
+}{
+	{header: "X-Drupal-Cache", tech: "Drupal"},
+	{header: "X-Varnish", tech: "Varnish"},
+	{header: "CF-Ray", tech: "Cloudflare"},
+	{header: "X-Amz-Cf-Id", tech: "Amazon CloudFront"},
+	{header: "X-Jenkins", tech: "Jenkins"},
+	{header: "X-Runtime", tech: "Ruby"},
+	{cookie: "PHPSESSID", tech: "PHP"},
+	{cookie: "JSESSIONID", tech: "Java"},
+	{cookie: "ASP.NET_SessionId", tech: "ASP.NET"},
+	{cookie: "laravel_session", tech: "Laravel"},
+	{cookie: "csrftoken", tech: "Django"},
+	{cookie: "_rails_session", tech: "Ruby on Rails"},
+	{cookie: "wordpress_test_cookie", tech: "WordPress"},
+}
+
+// techHints returns technologies hinted by headers and cookies of resp
+func techHints(resp *http.Response) []string {
+	var hints []string
+	add := func(tech string) {
+		// skip hints already known, eg. PHP after X-Powered-By: PHP/8.1
+		for _, hint := range hints {
+			if strings.HasPrefix(hint, tech) {
+				return
+			}
+		}
+		if tech != "" {
+			hints = append(hints, tech)
+		}
+	}
+
+	for _, header := range techHeaders {
+		for _, value := range resp.Header[http.CanonicalHeaderKey(header)] {
+			add(value)
+		}
+	}
+	cookies := map[string]bool{}
+	for _, cookie := range resp.Cookies() {
+		cookies[cookie.Name] = true
+	}
+	for _, marker := range techMarkers {
+		if marker.header != "" && resp.Header.Get(marker.header) != "" {
+			add(marker.tech)
+		}
+		if marker.cookie != "" && cookies[marker.cookie] {
+			add(marker.tech)
+		}
+	}
+
+	return hints
+}
@@ -0,0 +1,168 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// serve starts a web server redirecting / to location, /a to /final and
+// serving a page at /final, and returns it with its host and port
+func serve(t *testing.T, location func(port int) string, useTLS bool) (*httptest.Server, string, int) {
+	t.Helper()
+
+	var port int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, location(port), http.StatusFound)
+	})
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/final", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/final", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "nginx/1.18.0")
+		w.Header().Set("X-Powered-By", "PHP/8.1")
+		http.SetCookie(w, &http.Cookie{Name: "PHPSESSID", Value: "1"})
+		fmt.Fprint(w, "<html><head><TITLE lang=\"en\">\n  Welcome &amp;\n  hello </TITLE></head></html>")
+	})
+
+	server := httptest.NewUnstartedServer(mux)
+	if useTLS {
+		server.StartTLS()
+	} else {
+		server.Start()
+	}
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ = strconv.Atoi(u.Port())
+
+	return server, u.Hostname(), port
+}
+
+func TestFetch(t *testing.T) {
+	relative := func(int) string { return "/a" }
+	final := &Page{Status: 200, Server: "nginx/1.18.0", Title: "Welcome & hello", Tech: []string{"PHP/8.1"}}
+
+	tests := []struct {
+		name         string
+		location     func(port int) string
+		maxRedirects int
+		useTLS       bool
+		wantPath     string
+		wantStatus   int
+		want         *Page
+		redirects    int
+	}{
+		{
+			name:       "redirects not followed",
+			location:   relative,
+			wantPath:   "/",
+			wantStatus: http.StatusFound,
+		},
+		{
+			name:         "redirects followed up to the limit",
+			location:     relative,
+			maxRedirects: 1,
+			wantPath:     "/a",
+			wantStatus:   http.StatusMovedPermanently,
+			redirects:    1,
+		},
+		{
+			name:         "page the redirects lead to",
+			location:     relative,
+			maxRedirects: 5,
+			wantPath:     "/final",
+			want:         final,
+			redirects:    2,
+		},
+		{
+			name:         "same host",
+			location:     func(port int) string { return "http://127.0.0.1:" + strconv.Itoa(port) + "/final" },
+			maxRedirects: 5,
+			wantPath:     "/final",
+			want:         final,
+			redirects:    1,
+		},
+		{
+			name:         "same host over TLS",
+			location:     relative,
+			maxRedirects: 5,
+			useTLS:       true,
+			wantPath:     "/final",
+			want:         final,
+			redirects:    2,
+		},
+		{
+			name:         "other host",
+			location:     func(port int) string { return "http://localhost:" + strconv.Itoa(port) + "/final" },
+			maxRedirects: 5,
+			wantPath:     "/",
+			wantStatus:   http.StatusFound,
+		},
+		{
+			name:         "other scheme",
+			location:     func(int) string { return "ftp://127.0.0.1/final" },
+			maxRedirects: 5,
+			wantPath:     "/",
+			wantStatus:   http.StatusFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, host, port := serve(t, tt.location, tt.useTLS)
+			defer server.Close()
+
+			myFetcher := &Fetcher{Timeout: 5 * time.Second, MaxRedirects: tt.maxRedirects}
+			page, err := myFetcher.Fetch(context.Background(), host, port, tt.useTLS)
+			if err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+
+			want := tt.want
+			if want == nil {
+				want = &Page{Status: tt.wantStatus}
+			}
+			u, err := url.Parse(page.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.Path != tt.wantPath || page.Redirects != tt.redirects {
+				t.Errorf("Fetch() = %s after %d redirects, want %s after %d", page.URL, page.Redirects, tt.wantPath, tt.redirects)
+			}
+			if page.Status != want.Status || page.Server != want.Server || page.Title != want.Title || !reflect.DeepEqual(page.Tech, want.Tech) {
+				t.Errorf("Fetch() = %d %q %q %v, want %d %q %q %v", page.Status, page.Server, page.Title, page.Tech, want.Status, want.Server, want.Title, want.Tech)
+			}
+		})
+	}
+}
+
+func TestTitle(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "no title", body: "<html><body>hi</body></html>", want: ""},
+		{name: "title", body: "<title>Index of /</title>", want: "Index of /"},
+		{name: "attributes and case", body: `<TITLE id="t">Login</TITLE>`, want: "Login"},
+		{name: "lines and entities", body: "<title>\n\tR&amp;D\n  wiki\n</title>", want: "R&D wiki"},
+		{name: "first title", body: "<title>one</title><title>two</title>", want: "one"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := title([]byte(tt.body)); got != tt.want {
+				t.Errorf("title(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
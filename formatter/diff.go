@@ -0,0 +1,415 @@
+package formatter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/butageek/netool/digger"
+	"github.com/butageek/netool/scanner"
+	"github.com/butageek/netool/target"
+)
+
+// kinds of changes between two scans
+const (
+	ChangeNewHost       = "new host"
+	ChangeVanishedHost  = "vanished host"
+	ChangeMAC           = "mac changed"
+	ChangeVendor        = "vendor changed"
+	ChangeOpened        = "port opened"
+	ChangeClosed        = "port closed"
+	ChangeBanner        = "banner changed"
+	ChangeVersion       = "version changed"
+	ChangeServer        = "server changed"
+	ChangeCertificate   = "certificate changed"
+	ChangeRecordAdded   = "record added"
+	ChangeRecordRemoved = "record removed"
+)
+
+// Snapshot results of a scan read back from its NDJSON stream
+// Summary is nil if the stream has no summary record
+type Snapshot struct {
+	Hosts   map[string]HostRecord
+	Ports   map[string]PortRecord
+	Summary *SummaryRecord
+}
+
+// Change struct of a difference between two scans
+// Port and Protocol are only set for port changes
+type Change struct {
+	Kind     string `json:"change"`
+	Host     string `json:"host"`
+	Port     int    `json:"port,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+	Old      string `json:"old,omitempty"`
+	New      string `json:"new,omitempty"`
+}
+
+// NewSnapshot returns an empty snapshot
+func NewSnapshot() *Snapshot {
+	return &Snapshot{
+		Hosts: map[string]HostRecord{},
+		Ports: map[string]PortRecord{},
+	}
+}
+
+// AddHost adds record of a host to the snapshot
+func (s *Snapshot) AddHost(record HostRecord) {
+	s.Hosts[record.IP] = record
+}
+
+// AddPort adds record of a port to the snapshot
+func (s *Snapshot) AddPort(record PortRecord) {
+	s.Ports[recordKey(record)] = record
+}
+
+// ReadSnapshot reads a scan written by Stream
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	snapshot := NewSnapshot()
+
+	lineScanner := bufio.NewScanner(r)
+	lineScanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; lineScanner.Scan(); line++ {
+		data := lineScanner.Bytes()
+		if len(strings.TrimSpace(string(data))) == 0 {
+			continue
+		}
+
+		var record struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		var err error
+		switch record.Type {
+		case RecordHost:
+			var host HostRecord
+			if err = json.Unmarshal(data, &host); err == nil {
+				snapshot.AddHost(host)
+			}
+		case RecordPort:
+			var port PortRecord
+			if err = json.Unmarshal(data, &port); err == nil {
+				snapshot.AddPort(port)
+			}
+		case RecordSummary:
+			var summary SummaryRecord
+			if err = json.Unmarshal(data, &summary); err == nil {
+				snapshot.Summary = &summary
+			}
+		default:
+			err = fmt.Errorf("unknown record type %q", record.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+	if err := lineScanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// Diff returns changes from before to after scan sorted by host and port
+// Only open ports count, a port is closed if it's not open anymore or
+// missing from after scan while in its scope. Hosts and ports missing from
+// after scan but out of its scope, or from an interrupted after scan, weren't
+// scanned and are skipped. MAC, vendor, banner, version, web server and
+// certificate are only compared if both scans found them, as they depend on
+// the options of the scan
+func Diff(before, after *Snapshot) []Change {
+	changes := []Change{}
+	covered := scanCoverage(after.Summary)
+
+	for ip, host := range after.Hosts {
+		oldHost, ok := before.Hosts[ip]
+		if !ok {
+			changes = append(changes, Change{Kind: ChangeNewHost, Host: ip, New: hostLabel(host)})
+			continue
+		}
+		if oldHost.MAC != "" && host.MAC != "" && oldHost.MAC != host.MAC {
+			changes = append(changes, Change{Kind: ChangeMAC, Host: ip, Old: oldHost.MAC, New: host.MAC})
+		}
+		if oldHost.Vendor != "" && host.Vendor != "" && oldHost.Vendor != host.Vendor {
+			changes = append(changes, Change{Kind: ChangeVendor, Host: ip, Old: oldHost.Vendor, New: host.Vendor})
+		}
+	}
+	for ip, host := range before.Hosts {
+		if _, ok := after.Hosts[ip]; !ok && covered.host(ip) {
+			changes = append(changes, Change{Kind: ChangeVanishedHost, Host: ip, Old: hostLabel(host)})
+		}
+	}
+
+	for key, port := range after.Ports {
+		if port.State != string(scanner.StateOpen) {
+			continue
+		}
+		oldPort, ok := before.Ports[key]
+		if !ok || oldPort.State != string(scanner.StateOpen) {
+			change := portChange(ChangeOpened, port)
+			change.Old = oldPort.State
+			change.New = port.State
+			changes = append(changes, change)
+			continue
+		}
+		if oldPort.Banner != "" && port.Banner != "" && oldPort.Banner != port.Banner {
+			change := portChange(ChangeBanner, port)
+			change.Old = oldPort.Banner
+			change.New = port.Banner
+			changes = append(changes, change)
+		}
+		oldVersion, version := productVersion(oldPort), productVersion(port)
+		if oldVersion != "" && version != "" && oldVersion != version {
+			change := portChange(ChangeVersion, port)
+			change.Old = oldVersion
+			change.New = version
+			changes = append(changes, change)
+		}
+		if oldPort.HTTP != nil && port.HTTP != nil && oldPort.HTTP.Server != port.HTTP.Server {
+			change := portChange(ChangeServer, port)
+			change.Old = oldPort.HTTP.Server
+			change.New = port.HTTP.Server
+			changes = append(changes, change)
+		}
+		if oldPort.TLS != nil && port.TLS != nil && certLabel(oldPort.TLS) != certLabel(port.TLS) {
+			change := portChange(ChangeCertificate, port)
+			change.Old = certLabel(oldPort.TLS)
+			change.New = certLabel(port.TLS)
+			changes = append(changes, change)
+		}
+	}
+	for key, oldPort := range before.Ports {
+		if oldPort.State != string(scanner.StateOpen) {
+			continue
+		}
+		port, ok := after.Ports[key]
+		if !ok && !covered.port(oldPort) {
+			continue
+		}
+		if !ok || port.State != string(scanner.StateOpen) {
+			change := portChange(ChangeClosed, oldPort)
+			change.Old = oldPort.State
+			change.New = port.State
+			changes = append(changes, change)
+		}
+	}
+
+	sortChanges(changes)
+
+	return changes
+}
+
+// coverage hosts and ports a scan covered, a nil coverage covers everything
+// ports is nil for scans of hosts
+type coverage struct {
+	hosts    map[string]bool
+	ports    map[int]bool
+	protocol string
+}
+
+// scanCoverage returns what the scan of summary covered
+// Scans without scope cover everything and interrupted scans nothing
+func scanCoverage(summary *SummaryRecord) *coverage {
+	switch {
+	case summary == nil:
+		return nil
+	case summary.Interrupted:
+		return &coverage{}
+	case summary.Scope == nil:
+		return nil
+	}
+
+	scope := summary.Scope
+	covered := &coverage{hosts: map[string]bool{}, protocol: scope.Protocol}
+	hosts, err := target.Parse(scope.Targets)
+	if err != nil {
+		return &coverage{}
+	}
+	exclude, err := target.ParseExclusions(scope.Exclude)
+	if err != nil {
+		return &coverage{}
+	}
+	hosts, _ = exclude.Filter(hosts)
+	for _, host := range hosts {
+		covered.hosts[hostKey(host)] = true
+	}
+	if scope.Ports != "" {
+		ports, err := scanner.ParsePorts(scope.Ports)
+		if err != nil {
+			return &coverage{}
+		}
+		covered.ports = map[int]bool{}
+		for _, port := range ports {
+			covered.ports[port] = true
+		}
+	}
+
+	return covered
+}
+
+// host reports whether the scan looked for host being alive
+func (c *coverage) host(host string) bool {
+	if c == nil {
+		return true
+	}
+
+	return c.ports == nil && c.hosts[hostKey(host)]
+}
+
+// port reports whether the scan probed the port of record
+func (c *coverage) port(record PortRecord) bool {
+	if c == nil {
+		return true
+	}
+
+	return c.hosts[hostKey(record.Host)] && c.ports[record.Port] && c.protocol == record.Protocol
+}
+
+// hostKey returns IPs in canonical form and hostnames in lower case
+func hostKey(host string) string {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// DiffRecords returns changes of DNS records from before to after lookup
+// TTLs are not compared, as they count down in caching resolvers. Types
+// whose lookup failed in either are skipped
+func DiffRecords(before, after *digger.Records) []Change {
+	changes := []Change{}
+
+	beforeSet, afterSet := recordSet(before), recordSet(after)
+	for _, record := range after.Entries {
+		label := recordLabel(record)
+		if failed(before, record.Type) {
+			continue
+		}
+		if !beforeSet[label] {
+			// mark as seen, answers may hold duplicates
+			beforeSet[label] = true
+			changes = append(changes, Change{Kind: ChangeRecordAdded, Host: after.Domain, New: label})
+		}
+	}
+	for _, record := range before.Entries {
+		label := recordLabel(record)
+		if failed(after, record.Type) {
+			continue
+		}
+		if !afterSet[label] {
+			afterSet[label] = true
+			changes = append(changes, Change{Kind: ChangeRecordRemoved, Host: before.Domain, Old: label})
+		}
+	}
+
+	return changes
+}
+
+// failed reports whether lookup of recordType failed for records
+func failed(records *digger.Records, recordType string) bool {
+	_, ok := records.Errors[recordType]
+
+	return ok
+}
+
+// recordSet returns labels of records
+func recordSet(records *digger.Records) map[string]bool {
+	set := map[string]bool{}
+	for _, record := range records.Entries {
+		set[recordLabel(record)] = true
+	}
+
+	return set
+}
+
+// recordLabel returns type and value of a DNS record, eg. MX 10 mail.example.com
+func recordLabel(record digger.Record) string {
+	if record.Type == digger.TypeMX {
+		return record.Type + " " + strconv.Itoa(int(record.Priority)) + " " + record.Value
+	}
+
+	return record.Type + " " + record.Value
+}
+
+// portChange returns a change of kind for the port of record
+func portChange(kind string, record PortRecord) Change {
+	return Change{
+		Kind:     kind,
+		Host:     record.Host,
+		Port:     record.Port,
+		Protocol: record.Protocol,
+	}
+}
+
+// recordKey identifies the port of a record
+func recordKey(record PortRecord) string {
+	return record.Protocol + "/" + record.Host + "/" + strconv.Itoa(record.Port)
+}
+
+// hostLabel returns the hostname of a host or its MAC if it has none
+func hostLabel(host HostRecord) string {
+	if host.Hostname != "" {
+		return host.Hostname
+	}
+
+	return host.MAC
+}
+
+// productVersion returns product and version of a port, eg. OpenSSH 8.9p1
+func productVersion(record PortRecord) string {
+	return strings.TrimSpace(record.Product + " " + record.Version)
+}
+
+// certLabel returns subject, issuer and expiry of a certificate
+func certLabel(record *TLSRecord) string {
+	return record.Subject + " by " + record.Issuer + " until " + record.NotAfter.Format("2006-01-02")
+}
+
+// sortChanges sorts changes by host, port and protocol
+// Host changes go before port changes of the same host
+func sortChanges(changes []Change) {
+	sort.SliceStable(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.Host != b.Host {
+			return scanner.CompareHosts(a.Host, b.Host) < 0
+		}
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		return a.Kind < b.Kind
+	})
+}
+
+// AssembleDiffData assembles output data for scan diffs
+func (f *Formatter) AssembleDiffData(changes []Change) {
+	var data [][]string
+
+	for _, change := range changes {
+		port := ""
+		if change.Port > 0 {
+			port = strconv.Itoa(change.Port) + "/" + change.Protocol
+		}
+		row := []string{
+			change.Kind,
+			change.Host,
+			port,
+			truncate(change.Old),
+			truncate(change.New),
+		}
+		data = append(data, row)
+	}
+
+	f.Data = data
+}
@@ -0,0 +1,223 @@
+package formatter
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/butageek/netool/scanner"
+)
+
+func TestDiff(t *testing.T) {
+	host := HostRecord{Type: RecordHost, IP: "10.0.0.1", MAC: "00:11:22:33:44:55", Vendor: "Acme"}
+	port := PortRecord{Type: RecordPort, Host: "10.0.0.1", Port: 443, Protocol: "tcp", State: "open"}
+	expiry := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	tcp443 := &Scope{Targets: []string{"10.0.0.1"}, Ports: "443", Protocol: "tcp"}
+	scanned := func(scope *Scope) *SummaryRecord {
+		return &SummaryRecord{Type: RecordSummary, Scope: scope}
+	}
+
+	tests := []struct {
+		name   string
+		before func(s *Snapshot)
+		after  func(s *Snapshot)
+		want   []Change
+	}{
+		{
+			name:   "no changes",
+			before: func(s *Snapshot) { s.AddHost(host); s.AddPort(port) },
+			after:  func(s *Snapshot) { s.AddHost(host); s.AddPort(port) },
+			want:   []Change{},
+		},
+		{
+			name:   "new host",
+			before: func(s *Snapshot) {},
+			after:  func(s *Snapshot) { s.AddHost(host) },
+			want:   []Change{{Kind: ChangeNewHost, Host: "10.0.0.1", New: "00:11:22:33:44:55"}},
+		},
+		{
+			name:   "vanished host",
+			before: func(s *Snapshot) { s.AddHost(host) },
+			after:  func(s *Snapshot) {},
+			want:   []Change{{Kind: ChangeVanishedHost, Host: "10.0.0.1", Old: "00:11:22:33:44:55"}},
+		},
+		{
+			name:   "mac changed",
+			before: func(s *Snapshot) { s.AddHost(host) },
+			after: func(s *Snapshot) {
+				changed := host
+				changed.MAC = "66:77:88:99:aa:bb"
+				s.AddHost(changed)
+			},
+			want: []Change{{Kind: ChangeMAC, Host: "10.0.0.1", Old: "00:11:22:33:44:55", New: "66:77:88:99:aa:bb"}},
+		},
+		{
+			name:   "mac unknown in one scan",
+			before: func(s *Snapshot) { s.AddHost(host) },
+			after: func(s *Snapshot) {
+				unknown := host
+				unknown.MAC = ""
+				unknown.Vendor = ""
+				s.AddHost(unknown)
+			},
+			want: []Change{},
+		},
+		{
+			name:   "port opened",
+			before: func(s *Snapshot) {},
+			after:  func(s *Snapshot) { s.AddPort(port) },
+			want:   []Change{{Kind: ChangeOpened, Host: "10.0.0.1", Port: 443, Protocol: "tcp", New: "open"}},
+		},
+		{
+			name:   "port closed",
+			before: func(s *Snapshot) { s.AddPort(port) },
+			after: func(s *Snapshot) {
+				closed := port
+				closed.State = "closed"
+				s.AddPort(closed)
+			},
+			want: []Change{{Kind: ChangeClosed, Host: "10.0.0.1", Port: 443, Protocol: "tcp", Old: "open", New: "closed"}},
+		},
+		{
+			name:   "port missing within scope",
+			before: func(s *Snapshot) { s.AddPort(port) },
+			after:  func(s *Snapshot) { s.Summary = scanned(tcp443) },
+			want:   []Change{{Kind: ChangeClosed, Host: "10.0.0.1", Port: 443, Protocol: "tcp", Old: "open"}},
+		},
+		{
+			name:   "port not scanned",
+			before: func(s *Snapshot) { s.AddPort(port) },
+			after: func(s *Snapshot) {
+				s.Summary = scanned(&Scope{Targets: []string{"10.0.0.1"}, Ports: "22,80", Protocol: "tcp"})
+			},
+			want: []Change{},
+		},
+		{
+			name:   "protocol not scanned",
+			before: func(s *Snapshot) { s.AddPort(port) },
+			after: func(s *Snapshot) {
+				s.Summary = scanned(&Scope{Targets: []string{"10.0.0.1"}, Ports: "443", Protocol: "udp"})
+			},
+			want: []Change{},
+		},
+		{
+			name:   "host excluded",
+			before: func(s *Snapshot) { s.AddPort(port) },
+			after: func(s *Snapshot) {
+				s.Summary = scanned(&Scope{Targets: []string{"10.0.0.0/30"}, Exclude: []string{"10.0.0.1"}, Ports: "443", Protocol: "tcp"})
+			},
+			want: []Change{},
+		},
+		{
+			name:   "interrupted scan",
+			before: func(s *Snapshot) { s.AddHost(host); s.AddPort(port) },
+			after: func(s *Snapshot) {
+				s.Summary = scanned(tcp443)
+				s.Summary.Interrupted = true
+			},
+			want: []Change{},
+		},
+		{
+			name:   "host missing within scope",
+			before: func(s *Snapshot) { s.AddHost(host) },
+			after:  func(s *Snapshot) { s.Summary = scanned(&Scope{Targets: []string{"10.0.0.0/24"}}) },
+			want:   []Change{{Kind: ChangeVanishedHost, Host: "10.0.0.1", Old: "00:11:22:33:44:55"}},
+		},
+		{
+			name:   "host not scanned",
+			before: func(s *Snapshot) { s.AddHost(host) },
+			after:  func(s *Snapshot) { s.Summary = scanned(&Scope{Targets: []string{"10.0.1.0/24"}}) },
+			want:   []Change{},
+		},
+		{
+			name:   "hosts not scanned by port scan",
+			before: func(s *Snapshot) { s.AddHost(host) },
+			after:  func(s *Snapshot) { s.Summary = scanned(tcp443) },
+			want:   []Change{},
+		},
+		{
+			name: "server changed",
+			before: func(s *Snapshot) {
+				web := port
+				web.HTTP = &HTTPRecord{Status: 200, Server: "nginx/1.18.0"}
+				s.AddPort(web)
+			},
+			after: func(s *Snapshot) {
+				web := port
+				web.HTTP = &HTTPRecord{Status: 200, Server: "nginx/1.24.0"}
+				s.AddPort(web)
+			},
+			want: []Change{{Kind: ChangeServer, Host: "10.0.0.1", Port: 443, Protocol: "tcp", Old: "nginx/1.18.0", New: "nginx/1.24.0"}},
+		},
+		{
+			name: "certificate changed",
+			before: func(s *Snapshot) {
+				secure := port
+				secure.TLS = &TLSRecord{Subject: "example.com", Issuer: "Old CA", NotAfter: expiry}
+				s.AddPort(secure)
+			},
+			after: func(s *Snapshot) {
+				secure := port
+				secure.TLS = &TLSRecord{Subject: "example.com", Issuer: "New CA", NotAfter: expiry}
+				s.AddPort(secure)
+			},
+			want: []Change{{
+				Kind:     ChangeCertificate,
+				Host:     "10.0.0.1",
+				Port:     443,
+				Protocol: "tcp",
+				Old:      "example.com by Old CA until 2030-01-01",
+				New:      "example.com by New CA until 2030-01-01",
+			}},
+		},
+		{
+			name: "not fingerprinted in one scan",
+			before: func(s *Snapshot) {
+				web := port
+				web.HTTP = &HTTPRecord{Status: 200, Server: "nginx/1.18.0"}
+				s.AddPort(web)
+			},
+			after: func(s *Snapshot) { s.AddPort(port) },
+			want:  []Change{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, after := NewSnapshot(), NewSnapshot()
+			tt.before(before)
+			tt.after(after)
+			if got := Diff(before, after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffStreams(t *testing.T) {
+	stream := func(mac string) *Snapshot {
+		parsed, err := net.ParseMAC(mac)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		st := NewStream(&buf)
+		st.Host(scanner.Host{IP: net.ParseIP("10.0.0.1"), MAC: parsed, Method: scanner.MethodARP})
+		if err := st.Summary("net", &Scope{Targets: []string{"10.0.0.0/24"}}, scanner.Stats{Targets: 1}, false); err != nil {
+			t.Fatal(err)
+		}
+		snapshot, err := ReadSnapshot(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return snapshot
+	}
+
+	got := Diff(stream("00:11:22:33:44:55"), stream("66:77:88:99:aa:bb"))
+	want := []Change{{Kind: ChangeMAC, Host: "10.0.0.1", Old: "00:11:22:33:44:55", New: "66:77:88:99:aa:bb"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %+v, want %+v", got, want)
+	}
+}
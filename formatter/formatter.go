@@ -2,23 +2,29 @@ package formatter
 
 import (
 	"fmt"
+	"math"
 	"net"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/butageek/netool/digger"
+	"github.com/butageek/netool/inspector"
 	"github.com/butageek/netool/reference"
-	"github.com/google/gopacket/macs"
-	"github.com/mostlygeek/arp"
+	"github.com/butageek/netool/scanner"
 	"github.com/olekukonko/tablewriter"
 )
 
 // Formatter struct of Formatter
+// Certificates expiring within ExpiryDays days are flagged in TLS columns
 type Formatter struct {
 	Header          []string
 	Data            [][]string
 	Border          bool
 	Separator       string
 	ColumnSeparator string
+	ExpiryDays      int
 }
 
 // Print prints formatted table of data
@@ -34,18 +40,58 @@ func (f *Formatter) Print() {
 	fmt.Println()
 }
 
+// AssembleDigData assembles output data for digger
+// records are grouped by type with an empty row between groups
+func (f *Formatter) AssembleDigData(records *digger.Records) {
+	var data [][]string
+
+	for _, recordType := range []string{digger.TypeA, digger.TypeAAAA, digger.TypeCNAME, digger.TypeNS, digger.TypeMX} {
+		group := records.ByType(recordType)
+		if len(group) == 0 {
+			continue
+		}
+		if len(data) > 0 {
+			data = append(data, []string{"", "", "", "", ""})
+		}
+
+		for _, record := range group {
+			priority := ""
+			if record.Type == digger.TypeMX {
+				priority = strconv.Itoa(int(record.Priority))
+			}
+			row := []string{
+				record.Name,
+				record.Type,
+				strconv.FormatUint(uint64(record.TTL), 10),
+				priority,
+				record.Value,
+			}
+			data = append(data, row)
+		}
+	}
+
+	f.Data = data
+}
+
+// maxCellLen longest free text shown in a table cell
+const maxCellLen = 60
+
 // AssemblePortData assembles output data for port scanner
-func (f *Formatter) AssemblePortData(ports []int, pra *reference.PortRefArray) {
+// columns are picked by Header, results are grouped by host with an empty
+// row between hosts
+func (f *Formatter) AssemblePortData(results []scanner.PortResult, pra *reference.PortRefArray) {
 	var data [][]string
 
-	for _, port := range ports {
-		// lookup port reference for port name and port description
-		portRef := pra.Find(port)
-		row := []string{
-			strconv.Itoa(port),
-			"TCP",
-			portRef.Name,
-			portRef.Desc,
+	for i, result := range results {
+		if i > 0 && result.Host != results[i-1].Host {
+			data = append(data, make([]string, len(f.Header)))
+		}
+
+		// lookup port reference for port description
+		portRef := pra.Find(result.Port, result.Protocol)
+		var row []string
+		for _, column := range f.Header {
+			row = append(row, f.portColumn(column, &result, portRef))
 		}
 		data = append(data, row)
 	}
@@ -53,41 +99,163 @@ func (f *Formatter) AssemblePortData(ports []int, pra *reference.PortRefArray) {
 	f.Data = data
 }
 
+// portColumn returns value of column for the port result
+func (f *Formatter) portColumn(column string, result *scanner.PortResult, portRef *reference.PortRef) string {
+	switch column {
+	case "Host":
+		return result.Host
+	case "Port":
+		return strconv.Itoa(result.Port)
+	case "Protocol":
+		return strings.ToUpper(result.Protocol)
+	case "State":
+		return string(result.State)
+	case "Reason":
+		return truncate(result.Reason)
+	case "Service Name":
+		return result.Service
+	case "Banner":
+		return truncate(result.Banner)
+	case "Product":
+		return result.Product
+	case "Version":
+		// extra info follows the version like nmap shows it
+		if result.Info != "" {
+			return strings.TrimSpace(result.Version + " (" + result.Info + ")")
+		}
+		return result.Version
+	case "CPE":
+		return result.CPE
+	case "Description":
+		return portRef.Desc
+	case "Latency":
+		return result.Latency.Round(time.Microsecond).String()
+	case "TLS":
+		if result.TLS != nil {
+			return result.TLS.Version
+		}
+	case "Certificate":
+		if result.TLS != nil {
+			return truncate(result.TLS.Subject)
+		}
+	case "Expires":
+		if result.TLS != nil {
+			return f.expiry(result.TLS)
+		}
+	case "TLS Problems":
+		if result.TLS != nil {
+			return strings.Join(result.TLS.Problems, ", ")
+		}
+	case "HTTP Status":
+		if result.HTTP != nil {
+			return strconv.Itoa(result.HTTP.Status)
+		}
+	case "Server":
+		if result.HTTP != nil {
+			return truncate(result.HTTP.Server)
+		}
+	case "Title":
+		if result.HTTP != nil {
+			return truncate(result.HTTP.Title)
+		}
+	case "Content Length":
+		if result.HTTP != nil && result.HTTP.ContentLength >= 0 {
+			return strconv.FormatInt(result.HTTP.ContentLength, 10)
+		}
+	case "Tech":
+		if result.HTTP != nil {
+			return truncate(strings.Join(result.HTTP.Tech, ", "))
+		}
+	}
+
+	return ""
+}
+
+// expiry returns expiry date of the certificate, flagged if it expires
+// within ExpiryDays
+func (f *Formatter) expiry(report *inspector.Report) string {
+	date := report.NotAfter.Format("2006-01-02")
+	if !report.ExpiresWithin(time.Duration(f.ExpiryDays) * 24 * time.Hour) {
+		return date
+	}
+
+	left := time.Until(report.NotAfter)
+	if left < 0 {
+		return date + " (EXPIRED)"
+	}
+	days := int(math.Ceil(left.Hours() / 24))
+
+	return date + " (EXPIRES IN " + strconv.Itoa(days) + "D)"
+}
+
+// truncate shortens text to fit in a table cell
+// Text is cut between characters, not within multibyte ones
+func truncate(text string) string {
+	runes := []rune(text)
+	if len(runes) <= maxCellLen {
+		return text
+	}
+
+	return string(runes[:maxCellLen-3]) + "..."
+}
+
 // AssembleNetData assembles output data for net scanner
-func (f *Formatter) AssembleNetData(ips []net.IP) {
+func (f *Formatter) AssembleNetData(hosts []scanner.Host) {
 	var data [][]string
-	var row []string
-
-	for _, ip := range ips {
-		// search MAC address in local ARP table for given IP address
-		macStr := arp.Search(ip.String())
-		mac, err := net.ParseMAC(macStr)
-		if err != nil {
-			row = []string{
-				ip.String(),
-				"UP",
-				"",
-				"",
-			}
-			data = append(data, row)
-			continue
-		}
 
-		// lookup for manufacturer based on first 3 bytes of MAC address
-		prefix := [3]byte{
-			mac[0],
-			mac[1],
-			mac[2],
+	for _, host := range hosts {
+		mac := ""
+		if host.MAC != nil {
+			mac = host.MAC.String()
 		}
-		manufacturer := macs.ValidMACPrefixMap[prefix]
-		row = []string{
-			ip.String(),
+		row := []string{
+			host.IP.String(),
 			"UP",
-			mac.String(),
-			manufacturer,
+			host.Hostname,
+			mac,
+			host.Vendor,
+			host.RTT.Round(time.Microsecond).String(),
+			host.Method,
 		}
 		data = append(data, row)
 	}
 
 	f.Data = data
 }
+
+// AssembleTLSData assembles output data for TLS inspection
+// each report is listed field by field with an empty row between reports
+func (f *Formatter) AssembleTLSData(reports []*inspector.Report) {
+	var data [][]string
+
+	for _, report := range reports {
+		if len(data) > 0 {
+			data = append(data, []string{"", ""})
+		}
+
+		key := report.KeyType
+		if report.KeySize > 0 {
+			key += " " + strconv.Itoa(report.KeySize) + " bits"
+		}
+		problems := strings.Join(report.Problems, ", ")
+		if problems == "" {
+			problems = "none"
+		}
+		rows := [][]string{
+			{"Target", net.JoinHostPort(report.Host, strconv.Itoa(report.Port))},
+			{"Version", report.Version},
+			{"Cipher", report.Cipher},
+			{"Subject", report.Subject},
+			{"SANs", strings.Join(report.SANs, ", ")},
+			{"Issuer", report.Issuer},
+			{"Not Before", report.NotBefore.Format("2006-01-02 15:04:05 MST")},
+			{"Not After", f.expiry(report)},
+			{"Key", key},
+			{"Chain Length", strconv.Itoa(report.Chain)},
+			{"Problems", problems},
+		}
+		data = append(data, rows...)
+	}
+
+	f.Data = data
+}
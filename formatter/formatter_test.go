@@ -0,0 +1,33 @@
+package formatter
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "short", text: "OpenSSH 8.9p1", want: "OpenSSH 8.9p1"},
+		{name: "fits", text: strings.Repeat("a", maxCellLen), want: strings.Repeat("a", maxCellLen)},
+		{name: "too long", text: strings.Repeat("a", maxCellLen+1), want: strings.Repeat("a", maxCellLen-3) + "..."},
+		{name: "multibyte fits", text: strings.Repeat("é", maxCellLen), want: strings.Repeat("é", maxCellLen)},
+		{name: "multibyte too long", text: strings.Repeat("日本", maxCellLen), want: strings.Repeat("日本", maxCellLen/2-2) + "日..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncate(tt.text)
+			if got != tt.want {
+				t.Errorf("truncate(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("truncate(%q) = %q, not valid UTF-8", tt.text, got)
+			}
+		})
+	}
+}
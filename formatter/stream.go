@@ -0,0 +1,237 @@
+package formatter
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/butageek/netool/scanner"
+)
+
+// types of stream records
+const (
+	RecordHost    = "host"
+	RecordPort    = "port"
+	RecordSummary = "summary"
+)
+
+// HostRecord stream record of a host found alive
+type HostRecord struct {
+	Type     string  `json:"type"`
+	IP       string  `json:"ip"`
+	MAC      string  `json:"mac,omitempty"`
+	Vendor   string  `json:"vendor,omitempty"`
+	Hostname string  `json:"hostname,omitempty"`
+	RTT      float64 `json:"rtt_ms"`
+	Method   string  `json:"method"`
+}
+
+// PortRecord stream record of a scanned port
+// TLS and HTTP are only set for ports inspected or fingerprinted
+type PortRecord struct {
+	Type     string      `json:"type"`
+	Host     string      `json:"host"`
+	Port     int         `json:"port"`
+	Protocol string      `json:"protocol"`
+	State    string      `json:"state"`
+	Reason   string      `json:"reason,omitempty"`
+	Service  string      `json:"service,omitempty"`
+	Latency  float64     `json:"latency_ms"`
+	Banner   string      `json:"banner,omitempty"`
+	Product  string      `json:"product,omitempty"`
+	Version  string      `json:"version,omitempty"`
+	Info     string      `json:"info,omitempty"`
+	CPE      string      `json:"cpe,omitempty"`
+	TLS      *TLSRecord  `json:"tls,omitempty"`
+	HTTP     *HTTPRecord `json:"http,omitempty"`
+}
+
+// TLSRecord certificate and connection details of a TLS port
+type TLSRecord struct {
+	Version   string    `json:"version"`
+	Cipher    string    `json:"cipher"`
+	Subject   string    `json:"subject"`
+	SANs      []string  `json:"sans,omitempty"`
+	Issuer    string    `json:"issuer"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	KeyType   string    `json:"key_type"`
+	KeySize   int       `json:"key_size,omitempty"`
+	Chain     int       `json:"chain"`
+	Problems  []string  `json:"problems,omitempty"`
+}
+
+// HTTPRecord fingerprint of a web server
+// ContentLength is -1 if unknown
+type HTTPRecord struct {
+	URL           string   `json:"url"`
+	Status        int      `json:"status"`
+	Server        string   `json:"server,omitempty"`
+	Title         string   `json:"title,omitempty"`
+	ContentLength int64    `json:"content_length"`
+	Tech          []string `json:"tech,omitempty"`
+	Redirects     int      `json:"redirects,omitempty"`
+}
+
+// Scope targets, exclusions and ports of a scan as given on the command line
+// Ports and Protocol are empty for scans of hosts
+type Scope struct {
+	Targets  []string `json:"targets"`
+	Exclude  []string `json:"exclude,omitempty"`
+	Ports    string   `json:"ports,omitempty"`
+	Protocol string   `json:"protocol,omitempty"`
+}
+
+// SummaryRecord stream record closing a scan
+// Found is number of hosts or ports streamed
+type SummaryRecord struct {
+	Type        string  `json:"type"`
+	Command     string  `json:"command"`
+	Scope       *Scope  `json:"scope,omitempty"`
+	Targets     int     `json:"targets"`
+	Skipped     int     `json:"skipped"`
+	Found       int     `json:"found"`
+	Closed      int     `json:"closed,omitempty"`
+	Filtered    int     `json:"filtered,omitempty"`
+	Errors      int     `json:"errors,omitempty"`
+	Elapsed     float64 `json:"elapsed_s"`
+	Interrupted bool    `json:"interrupted"`
+}
+
+// Stream writes results as newline delimited JSON as they are found
+// It's safe for concurrent use
+type Stream struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	start time.Time
+	found int
+	err   error
+}
+
+// NewStream returns a stream writing to w
+func NewStream(w io.Writer) *Stream {
+	return &Stream{
+		enc:   json.NewEncoder(w),
+		start: time.Now(),
+	}
+}
+
+// NewHostRecord returns the record of host
+func NewHostRecord(host scanner.Host) HostRecord {
+	record := HostRecord{
+		Type:     RecordHost,
+		IP:       host.IP.String(),
+		Vendor:   host.Vendor,
+		Hostname: host.Hostname,
+		RTT:      milliseconds(host.RTT),
+		Method:   host.Method,
+	}
+	if host.MAC != nil {
+		record.MAC = host.MAC.String()
+	}
+
+	return record
+}
+
+// NewPortRecord returns the record of a port result
+func NewPortRecord(result scanner.PortResult) PortRecord {
+	record := PortRecord{
+		Type:     RecordPort,
+		Host:     result.Host,
+		Port:     result.Port,
+		Protocol: result.Protocol,
+		State:    string(result.State),
+		Reason:   result.Reason,
+		Service:  result.Service,
+		Latency:  milliseconds(result.Latency),
+		Banner:   result.Banner,
+		Product:  result.Product,
+		Version:  result.Version,
+		Info:     result.Info,
+		CPE:      result.CPE,
+	}
+	if report := result.TLS; report != nil {
+		record.TLS = &TLSRecord{
+			Version:   report.Version,
+			Cipher:    report.Cipher,
+			Subject:   report.Subject,
+			SANs:      report.SANs,
+			Issuer:    report.Issuer,
+			NotBefore: report.NotBefore,
+			NotAfter:  report.NotAfter,
+			KeyType:   report.KeyType,
+			KeySize:   report.KeySize,
+			Chain:     report.Chain,
+			Problems:  report.Problems,
+		}
+	}
+	if page := result.HTTP; page != nil {
+		record.HTTP = &HTTPRecord{
+			URL:           page.URL,
+			Status:        page.Status,
+			Server:        page.Server,
+			Title:         page.Title,
+			ContentLength: page.ContentLength,
+			Tech:          page.Tech,
+			Redirects:     page.Redirects,
+		}
+	}
+
+	return record
+}
+
+// Host writes record of host
+func (st *Stream) Host(host scanner.Host) {
+	st.write(NewHostRecord(host), true)
+}
+
+// Port writes record of a port result
+func (st *Stream) Port(result scanner.PortResult) {
+	st.write(NewPortRecord(result), true)
+}
+
+// Summary writes the summary record of the scan and returns the first error
+// writing the stream
+func (st *Stream) Summary(command string, scope *Scope, stats scanner.Stats, interrupted bool) error {
+	st.mu.Lock()
+	found := st.found
+	st.mu.Unlock()
+
+	st.write(SummaryRecord{
+		Type:        RecordSummary,
+		Command:     command,
+		Scope:       scope,
+		Targets:     stats.Targets,
+		Skipped:     stats.Skipped,
+		Found:       found,
+		Closed:      stats.Closed,
+		Filtered:    stats.Filtered,
+		Errors:      stats.Errors,
+		Elapsed:     time.Since(st.start).Seconds(),
+		Interrupted: interrupted,
+	}, false)
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return st.err
+}
+
+// write encodes record as one line, found counts it as found result
+func (st *Stream) write(record interface{}, found bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if found {
+		st.found++
+	}
+	if err := st.enc.Encode(record); err != nil && st.err == nil {
+		st.err = err
+	}
+}
+
+// milliseconds returns d in milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
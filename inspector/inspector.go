@@ -0,0 +1,215 @@
+package inspector
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+)
+
+// problems found in a certificate chain
+const (
+	ProblemExpired          = "expired"
+	ProblemChainExpired     = "expired chain"
+	ProblemNotYetValid      = "not yet valid"
+	ProblemSelfSigned       = "self-signed"
+	ProblemUnknownAuthority = "unknown authority"
+	ProblemHostname         = "hostname mismatch"
+	ProblemInvalidChain     = "invalid chain"
+)
+
+// Report certificate and connection details of a TLS service
+// Problems lists why the chain doesn't verify, it's empty for trusted
+// certificates
+type Report struct {
+	Host      string
+	Port      int
+	Version   string
+	Cipher    string
+	Subject   string
+	SANs      []string
+	Issuer    string
+	NotBefore time.Time
+	NotAfter  time.Time
+	KeyType   string
+	KeySize   int
+	Chain     int
+	Problems  []string
+}
+
+// ExpiresWithin reports whether the certificate expires within d from now
+func (r *Report) ExpiresWithin(d time.Duration) bool {
+	return time.Until(r.NotAfter) < d
+}
+
+// Inspector struct of Inspector
+// ServerName is sent as SNI and checked against the certificate, the host
+// is used if it's empty
+// RootCAs are the trusted roots, the system roots are used if it's nil
+type Inspector struct {
+	ServerName string
+	RootCAs    *x509.CertPool
+	Timeout    time.Duration
+}
+
+// Inspect does a TLS handshake with the port of host and reports the
+// certificate presented. Untrusted certificates are accepted and reported
+// with their problems
+func (i *Inspector) Inspect(host string, port int) (*Report, error) {
+	timeout := i.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	serverName := i.ServerName
+	if serverName == "" {
+		serverName = host
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, strconv.Itoa(port)), &tls.Config{
+		ServerName: serverName,
+		// the chain is verified below to report problems instead of failing
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	state := conn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return nil, errors.New("no certificate presented")
+	}
+	cert := state.PeerCertificates[0]
+	report := &Report{
+		Host:      host,
+		Port:      port,
+		Version:   versionName(state.Version),
+		Cipher:    tls.CipherSuiteName(state.CipherSuite),
+		Subject:   cert.Subject.String(),
+		SANs:      sans(cert),
+		Issuer:    cert.Issuer.String(),
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+		Chain:     len(state.PeerCertificates),
+	}
+	report.KeyType, report.KeySize = keyInfo(cert)
+	report.Problems = i.verify(state.PeerCertificates, serverName)
+
+	return report, nil
+}
+
+// verify verifies the chain presented by the server and returns its problems
+func (i *Inspector) verify(chain []*x509.Certificate, serverName string) []string {
+	var problems []string
+	cert := chain[0]
+
+	now := time.Now()
+	if now.After(cert.NotAfter) {
+		problems = append(problems, ProblemExpired)
+	}
+	if now.Before(cert.NotBefore) {
+		problems = append(problems, ProblemNotYetValid)
+	}
+	if err := cert.VerifyHostname(serverName); err != nil {
+		problems = append(problems, ProblemHostname)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range chain[1:] {
+		intermediates.AddCert(c)
+	}
+	verifyAt := func(at time.Time) error {
+		_, err := cert.Verify(x509.VerifyOptions{
+			Roots:         i.RootCAs,
+			Intermediates: intermediates,
+			CurrentTime:   at,
+		})
+		return err
+	}
+
+	// validity of the leaf is reported above, an invalid leaf is verified
+	// at the closest time it's valid to still find problems of the chain
+	at := now
+	if at.After(cert.NotAfter) {
+		at = cert.NotAfter
+	}
+	if at.Before(cert.NotBefore) {
+		at = cert.NotBefore
+	}
+	err := verifyAt(at)
+	// a chain valid when the leaf was issued has an expired or not yet
+	// valid issuer now
+	if err != nil && verifyAt(cert.NotBefore.Add(time.Second)) == nil {
+		return append(problems, ProblemChainExpired)
+	}
+	switch err.(type) {
+	case nil:
+	case x509.UnknownAuthorityError:
+		if isSelfSigned(cert) {
+			problems = append(problems, ProblemSelfSigned)
+		} else {
+			problems = append(problems, ProblemUnknownAuthority)
+		}
+	default:
+		problems = append(problems, ProblemInvalidChain)
+	}
+
+	return problems
+}
+
+// isSelfSigned reports whether cert is signed by its own key
+func isSelfSigned(cert *x509.Certificate) bool {
+	if cert.Subject.String() != cert.Issuer.String() {
+		return false
+	}
+
+	return cert.CheckSignatureFrom(cert) == nil
+}
+
+// sans returns DNS names and IP addresses the certificate is valid for
+func sans(cert *x509.Certificate) []string {
+	names := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+
+	return names
+}
+
+// keyInfo returns type and size in bits of the certificate's public key
+func keyInfo(cert *x509.Certificate) (string, int) {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return "RSA", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return "ECDSA", key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return "Ed25519", 256
+	}
+
+	return cert.PublicKeyAlgorithm.String(), 0
+}
+
+// versionName returns name of a TLS version
+func versionName(version uint16) string {
+	switch version {
+	case tls.VersionSSL30:
+		return "SSL 3.0"
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+
+	return "0x" + strconv.FormatUint(uint64(version), 16)
+}
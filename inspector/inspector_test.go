@@ -0,0 +1,172 @@
+package inspector
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// issue returns a certificate for 127.0.0.1 valid from notBefore to notAfter,
+// signed by parent or self-signed if parent is nil
+func issue(t *testing.T, cn string, notBefore, notAfter time.Time, isCA bool, parent *tls.Certificate) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	issuer, signer := template, interface{}(key)
+	if parent != nil {
+		issuer, signer = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// serve starts a TLS server presenting cert, or the httptest certificate if
+// cert is nil, and returns it with its host and port
+func serve(t *testing.T, cert *tls.Certificate) (*httptest.Server, string, int) {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	// Inspect hangs up after the handshake
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	if cert != nil {
+		server.TLS = &tls.Config{Certificates: []tls.Certificate{*cert}}
+	}
+	server.StartTLS()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ := strconv.Atoi(u.Port())
+
+	return server, u.Hostname(), port
+}
+
+func TestInspect(t *testing.T) {
+	now := time.Now()
+	ca := issue(t, "Test CA", now.Add(-72*time.Hour), now.Add(time.Hour), true, nil)
+	signed := issue(t, "signed", now.Add(-time.Hour), now.Add(time.Hour), false, &ca)
+	expired := issue(t, "expired", now.Add(-48*time.Hour), now.Add(-24*time.Hour), false, &ca)
+	otherCA := issue(t, "Other CA", now.Add(-time.Hour), now.Add(time.Hour), true, nil)
+	untrusted := issue(t, "untrusted", now.Add(-time.Hour), now.Add(time.Hour), false, &otherCA)
+	// the intermediate was valid when the leaf was issued but expired since
+	expiredCA := issue(t, "Expired CA", now.Add(-72*time.Hour), now.Add(-time.Minute), true, &ca)
+	behindExpired := issue(t, "behind expired", now.Add(-time.Hour), now.Add(time.Hour), false, &expiredCA)
+	behindExpired.Certificate = append(behindExpired.Certificate, expiredCA.Certificate[0])
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+
+	tests := []struct {
+		name       string
+		cert       *tls.Certificate
+		trustTest  bool
+		serverName string
+		want       []string
+	}{
+		{
+			name:      "valid chain",
+			trustTest: true,
+			want:      nil,
+		},
+		{
+			name: "valid chain signed by root",
+			cert: &signed,
+			want: nil,
+		},
+		{
+			name: "self-signed",
+			want: []string{ProblemSelfSigned},
+		},
+		{
+			name: "expired",
+			cert: &expired,
+			want: []string{ProblemExpired},
+		},
+		{
+			name:       "hostname mismatch",
+			trustTest:  true,
+			serverName: "mismatch.test",
+			want:       []string{ProblemHostname},
+		},
+		{
+			name: "unknown authority",
+			cert: &untrusted,
+			want: []string{ProblemUnknownAuthority},
+		},
+		{
+			name: "expired intermediate",
+			cert: &behindExpired,
+			want: []string{ProblemChainExpired},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, host, port := serve(t, tt.cert)
+			defer server.Close()
+
+			pool := roots
+			if tt.trustTest {
+				pool = x509.NewCertPool()
+				pool.AddCert(server.Certificate())
+			}
+			myInspector := &Inspector{
+				ServerName: tt.serverName,
+				RootCAs:    pool,
+				Timeout:    5 * time.Second,
+			}
+			report, err := myInspector.Inspect(host, port)
+			if err != nil {
+				t.Fatalf("Inspect() error = %v", err)
+			}
+			if !reflect.DeepEqual(report.Problems, tt.want) {
+				t.Errorf("Inspect() problems = %v, want %v", report.Problems, tt.want)
+			}
+			chain := 1
+			if tt.cert != nil {
+				chain = len(tt.cert.Certificate)
+			}
+			if report.Host != host || report.Port != port || report.Chain != chain {
+				t.Errorf("Inspect() = %s:%d with chain of %d, want %s:%d with chain of %d", report.Host, report.Port, report.Chain, host, port, chain)
+			}
+		})
+	}
+}
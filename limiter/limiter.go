@@ -0,0 +1,90 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter token bucket limiting the rate of probes shared by all workers
+// of a scan. Tokens are added at Rate per second up to Burst, so probes may
+// go out in bursts after idle periods. MaxRate caps the rate even within
+// bursts. A zero Rate or MaxRate leaves that limit off
+type Limiter struct {
+	Rate    float64
+	Burst   int
+	MaxRate float64
+
+	mu       sync.Mutex
+	tokens   float64
+	last     time.Time
+	nextPeak time.Time
+}
+
+// New returns a limiter with a full bucket
+func New(rate float64, burst int, maxRate float64) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &Limiter{
+		Rate:    rate,
+		Burst:   burst,
+		MaxRate: maxRate,
+		tokens:  float64(burst),
+		last:    time.Now(),
+	}
+}
+
+// Wait blocks until a probe may be sent
+// Returns ctx.Err() if ctx is done before. A nil limiter never blocks
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+
+	delay := l.reserve(time.Now())
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return ctx.Err()
+}
+
+// reserve takes a token and returns how long to wait until it's valid
+func (l *Limiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var delay time.Duration
+	if l.Rate > 0 {
+		// refill for the time passed, tokens go negative for reservations
+		// waiting in line
+		l.tokens += now.Sub(l.last).Seconds() * l.Rate
+		if l.tokens > float64(l.Burst) {
+			l.tokens = float64(l.Burst)
+		}
+		l.last = now
+		l.tokens--
+		if l.tokens < 0 {
+			delay = time.Duration(-l.tokens / l.Rate * float64(time.Second))
+		}
+	}
+	if l.MaxRate > 0 {
+		// space probes at least 1/MaxRate apart
+		at := now.Add(delay)
+		if l.nextPeak.After(at) {
+			at = l.nextPeak
+		}
+		l.nextPeak = at.Add(time.Duration(float64(time.Second) / l.MaxRate))
+		delay = at.Sub(now)
+	}
+
+	return delay
+}
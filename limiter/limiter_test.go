@@ -0,0 +1,109 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReserve(t *testing.T) {
+	ms := time.Millisecond
+
+	tests := []struct {
+		name    string
+		rate    float64
+		burst   int
+		maxRate float64
+		// times of reservations after the limiter was created
+		at   []time.Duration
+		want []time.Duration
+	}{
+		{
+			name: "no limits",
+			at:   []time.Duration{0, 0, 0},
+			want: []time.Duration{0, 0, 0},
+		},
+		{
+			name:  "burst then rate",
+			rate:  10,
+			burst: 3,
+			at:    []time.Duration{0, 0, 0, 0, 0},
+			want:  []time.Duration{0, 0, 0, 100 * ms, 200 * ms},
+		},
+		{
+			name:  "refill",
+			rate:  10,
+			burst: 1,
+			at:    []time.Duration{0, 0, 300 * ms, 300 * ms},
+			want:  []time.Duration{0, 100 * ms, 0, 100 * ms},
+		},
+		{
+			name:  "refill up to burst",
+			rate:  10,
+			burst: 2,
+			at:    []time.Duration{0, 0, 10 * time.Second, 10 * time.Second, 10 * time.Second},
+			want:  []time.Duration{0, 0, 0, 0, 100 * ms},
+		},
+		{
+			name:    "max rate within burst",
+			rate:    100,
+			burst:   10,
+			maxRate: 10,
+			at:      []time.Duration{0, 0, 0},
+			want:    []time.Duration{0, 100 * ms, 200 * ms},
+		},
+		{
+			name:    "max rate only",
+			maxRate: 4,
+			at:      []time.Duration{0, 0, 0, time.Second},
+			want:    []time.Duration{0, 250 * ms, 500 * ms, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(tt.rate, tt.burst, tt.maxRate)
+			start := time.Now()
+			l.last = start
+
+			for i, at := range tt.at {
+				got := l.reserve(start.Add(at))
+				// allow for rounding of float seconds
+				if diff := got - tt.want[i]; diff < -time.Microsecond || diff > time.Microsecond {
+					t.Errorf("reserve() #%d at %v = %v, want %v", i+1, at, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWaitCanceled(t *testing.T) {
+	l := New(0.1, 1, 0)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() error = %v, want a token of the full bucket", err)
+	}
+
+	// the next token is 10s away
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := l.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Wait() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Wait() returned after %v, want right after ctx is done", elapsed)
+	}
+}
+
+func TestWaitNil(t *testing.T) {
+	var l *Limiter
+	if err := l.Wait(context.Background()); err != nil {
+		t.Errorf("Wait() error = %v, want nil", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx); err != context.Canceled {
+		t.Errorf("Wait() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,112 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/butageek/netool/scanner"
+	"github.com/butageek/netool/target"
+)
+
+// types of jobs
+const (
+	JobNet  = "net"
+	JobPort = "port"
+	JobDig  = "dig"
+)
+
+// DefaultPorts ports scanned by port jobs not setting ports
+const DefaultPorts = "1-1023,3389"
+
+// MinInterval shortest interval between runs of a job
+const MinInterval = 10 * time.Second
+
+// Config struct of a monitor config file
+type Config struct {
+	Jobs []Job `mapstructure:"jobs"`
+}
+
+// Job struct of a scan run at Interval
+// Targets are CIDRs for net jobs, hosts, CIDRs or ranges for port jobs and
+// domains for dig jobs. Ports, UDP, Banner and Service only apply to port
+// jobs, ARP to net jobs and Server to dig jobs
+type Job struct {
+	Name     string        `mapstructure:"name"`
+	Type     string        `mapstructure:"type"`
+	Targets  []string      `mapstructure:"targets"`
+	Interval time.Duration `mapstructure:"interval"`
+	Exclude  []string      `mapstructure:"exclude"`
+	Rate     float64       `mapstructure:"rate"`
+	ARP      bool          `mapstructure:"arp"`
+	Ports    string        `mapstructure:"ports"`
+	UDP      bool          `mapstructure:"udp"`
+	Banner   bool          `mapstructure:"banner"`
+	Service  bool          `mapstructure:"service"`
+	Server   string        `mapstructure:"server"`
+}
+
+// Validate checks jobs of the config and fills in defaults
+func (c *Config) Validate() error {
+	if len(c.Jobs) == 0 {
+		return errors.New("no jobs configured")
+	}
+
+	names := map[string]bool{}
+	for i := range c.Jobs {
+		job := &c.Jobs[i]
+		if job.Name == "" {
+			return fmt.Errorf("job %d has no name", i+1)
+		}
+		if names[job.Name] {
+			return fmt.Errorf("job %s: name is used twice", job.Name)
+		}
+		names[job.Name] = true
+		if err := job.validate(); err != nil {
+			return fmt.Errorf("job %s: %v", job.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// validate checks the job and fills in defaults
+func (j *Job) validate() error {
+	if len(j.Targets) == 0 {
+		return errors.New("no targets")
+	}
+	if j.Interval < MinInterval {
+		return fmt.Errorf("interval must be at least %v", MinInterval)
+	}
+	if j.Rate < 0 {
+		return errors.New("rate can't be negative")
+	}
+	if _, err := target.ParseExclusions(j.Exclude); err != nil {
+		return err
+	}
+
+	switch j.Type {
+	case JobNet:
+		for _, cidr := range j.Targets {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return err
+			}
+		}
+	case JobPort:
+		if j.Ports == "" {
+			j.Ports = DefaultPorts
+		}
+		if _, err := scanner.ParsePorts(j.Ports); err != nil {
+			return err
+		}
+		if _, err := target.Parse(j.Targets); err != nil {
+			return err
+		}
+	case JobDig:
+	default:
+		return fmt.Errorf("unknown type %q, use net, port or dig", j.Type)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package monitor
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// log levels
+const (
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+// Fields additional fields of a log entry
+type Fields map[string]interface{}
+
+// Logger writes log entries as JSON lines with time, level and msg fields
+// It's safe for concurrent use
+type Logger struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewLogger returns a logger writing to w
+func NewLogger(w io.Writer) *Logger {
+	return &Logger{enc: json.NewEncoder(w)}
+}
+
+// Info logs msg at info level
+func (l *Logger) Info(msg string, fields Fields) {
+	l.log(LevelInfo, msg, fields)
+}
+
+// Warn logs msg at warn level
+func (l *Logger) Warn(msg string, fields Fields) {
+	l.log(LevelWarn, msg, fields)
+}
+
+// Error logs msg at error level
+func (l *Logger) Error(msg string, fields Fields) {
+	l.log(LevelError, msg, fields)
+}
+
+// log writes an entry, fields don't override time, level and msg
+func (l *Logger) log(level, msg string, fields Fields) {
+	entry := map[string]interface{}{}
+	for key, value := range fields {
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		entry[key] = value
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry["time"] = time.Now().Format(time.RFC3339)
+	entry["level"] = level
+	entry["msg"] = msg
+	// nowhere to report failed writes of the log
+	l.enc.Encode(entry)
+}
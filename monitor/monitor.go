@@ -0,0 +1,344 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/butageek/netool/digger"
+	"github.com/butageek/netool/formatter"
+	"github.com/butageek/netool/limiter"
+	"github.com/butageek/netool/scanner"
+	"github.com/butageek/netool/service"
+	"github.com/butageek/netool/target"
+)
+
+// Monitor runs jobs of a config at their intervals and logs changes of
+// results between runs as events
+// The last known state of each target is kept across reloads of the config
+// as long as the job scanning it doesn't change
+type Monitor struct {
+	Log *Logger
+
+	// scans run by jobs, tests replace them
+	scanNet  func(ctx context.Context, job Job, limit *limiter.Limiter, cidr string) ([]scanner.Host, error)
+	scanPort func(ctx context.Context, job Job, limit *limiter.Limiter, hosts []string) ([]scanner.PortResult, error)
+	dig      func(ctx context.Context, job Job, domain string) (*digger.Records, error)
+
+	mu     sync.Mutex
+	states map[string]*state
+}
+
+// state last known results of a target
+// job identifies the job settings the results were found with
+type state struct {
+	job      string
+	snapshot *formatter.Snapshot
+	records  *digger.Records
+}
+
+// New returns a monitor logging to log
+func New(log *Logger) *Monitor {
+	return &Monitor{
+		Log:      log,
+		scanNet:  scanNet,
+		scanPort: scanPort,
+		dig:      dig,
+		states:   map[string]*state{},
+	}
+}
+
+// Run runs jobs of config until ctx is done, switching to each config
+// received from reloads. Jobs run at start and after each reload, then at
+// their intervals. Scans in progress are canceled by reloads
+func (m *Monitor) Run(ctx context.Context, config *Config, reloads <-chan *Config) {
+	for {
+		m.prune(config)
+		m.Log.Info("jobs scheduled", Fields{"jobs": len(config.Jobs)})
+
+		jobCtx, cancel := context.WithCancel(ctx)
+		var wg sync.WaitGroup
+		for _, job := range config.Jobs {
+			wg.Add(1)
+			go func(job Job) {
+				defer wg.Done()
+				m.schedule(jobCtx, job)
+			}(job)
+		}
+
+		select {
+		case <-ctx.Done():
+			cancel()
+			wg.Wait()
+			m.Log.Info("monitor stopped", nil)
+			return
+		case config = <-reloads:
+			cancel()
+			wg.Wait()
+			m.Log.Info("config reloaded", nil)
+		}
+	}
+}
+
+// schedule runs job at start and at its interval until ctx is done
+func (m *Monitor) schedule(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.run(ctx, job)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// run runs job once and logs changes of its targets
+func (m *Monitor) run(ctx context.Context, job Job) {
+	start := time.Now()
+	var limit *limiter.Limiter
+	if job.Rate > 0 {
+		limit = limiter.New(job.Rate, 1, 0)
+	}
+
+	changes := 0
+	switch job.Type {
+	case JobNet:
+		for _, cidr := range job.Targets {
+			hosts, err := m.scanNet(ctx, job, limit, cidr)
+			if err != nil {
+				m.failed(ctx, job, cidr, err)
+				continue
+			}
+			snapshot := formatter.NewSnapshot()
+			for _, host := range hosts {
+				snapshot.AddHost(formatter.NewHostRecord(host))
+			}
+			changes += m.compare(job, cidr, snapshot)
+		}
+	case JobPort:
+		targets := strings.Join(job.Targets, ",")
+		hosts, err := target.Parse(job.Targets)
+		if err != nil {
+			m.failed(ctx, job, targets, err)
+			return
+		}
+		results, err := m.scanPort(ctx, job, limit, hosts)
+		if err != nil {
+			m.failed(ctx, job, targets, err)
+			return
+		}
+		snapshot := formatter.NewSnapshot()
+		for _, result := range results {
+			snapshot.AddPort(formatter.NewPortRecord(result))
+		}
+		changes += m.compare(job, targets, snapshot)
+	case JobDig:
+		for _, domain := range job.Targets {
+			records, err := m.dig(ctx, job, domain)
+			if err != nil {
+				m.failed(ctx, job, domain, err)
+				continue
+			}
+			for recordType, err := range records.Errors {
+				m.Log.Warn("lookup failed", Fields{
+					"job":    job.Name,
+					"target": domain,
+					"record": recordType,
+					"error":  err,
+				})
+			}
+			changes += m.compareRecords(job, domain, records)
+		}
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	m.Log.Info("job finished", Fields{
+		"job":        job.Name,
+		"duration_s": time.Since(start).Seconds(),
+		"changes":    changes,
+	})
+}
+
+// scanNet scans cidr for hosts alive with the settings of job
+func scanNet(ctx context.Context, job Job, limit *limiter.Limiter, cidr string) ([]scanner.Host, error) {
+	// settings were checked when the config was loaded
+	exclude, _ := target.ParseExclusions(job.Exclude)
+	myScanner := &scanner.Scanner{
+		ARP:     job.ARP,
+		Exclude: exclude,
+		Limiter: limit,
+	}
+
+	return myScanner.ScanNet(ctx, cidr)
+}
+
+// scanPort scans ports of the hosts with the settings of job
+func scanPort(ctx context.Context, job Job, limit *limiter.Limiter, hosts []string) ([]scanner.PortResult, error) {
+	exclude, _ := target.ParseExclusions(job.Exclude)
+	var services *service.DB
+	if job.Service {
+		var err error
+		if services, err = service.LoadDefault(); err != nil {
+			return nil, err
+		}
+	}
+	myScanner := &scanner.Scanner{
+		UDP:      job.UDP,
+		Exclude:  exclude,
+		Banner:   job.Banner,
+		Nudge:    scanner.DefaultNudge,
+		Services: services,
+		Limiter:  limit,
+	}
+
+	return myScanner.ScanPort(ctx, hosts, job.Ports)
+}
+
+// dig looks up records of domain with the settings of job
+func dig(ctx context.Context, job Job, domain string) (*digger.Records, error) {
+	myDigger := &digger.Digger{
+		Domain: domain,
+		Server: job.Server,
+	}
+
+	return myDigger.Dig(ctx)
+}
+
+// failed logs err of a job run, unless the run was canceled
+func (m *Monitor) failed(ctx context.Context, job Job, target string, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	m.Log.Error("job failed", Fields{
+		"job":    job.Name,
+		"target": target,
+		"error":  err,
+	})
+}
+
+// compare stores snapshot as state of target and logs changes from the
+// previous one, returns the number of changes
+func (m *Monitor) compare(job Job, target string, snapshot *formatter.Snapshot) int {
+	previous := m.swap(job, target, &state{snapshot: snapshot})
+	if previous == nil {
+		m.baseline(job, target)
+		return 0
+	}
+
+	changes := formatter.Diff(previous.snapshot, snapshot)
+	m.events(job, target, changes)
+
+	return len(changes)
+}
+
+// compareRecords stores records as state of target and logs changes from
+// the previous ones, returns the number of changes
+func (m *Monitor) compareRecords(job Job, target string, records *digger.Records) int {
+	previous := m.swap(job, target, &state{records: records})
+	if previous == nil {
+		m.baseline(job, target)
+		return 0
+	}
+
+	changes := formatter.DiffRecords(previous.records, records)
+	m.events(job, target, changes)
+	carryFailed(records, previous.records)
+
+	return len(changes)
+}
+
+// carryFailed keeps the last known records of types that failed to look up,
+// so they're compared again by the next run
+func carryFailed(records, previous *digger.Records) {
+	for recordType := range records.Errors {
+		if _, failed := previous.Errors[recordType]; failed {
+			continue
+		}
+		for _, record := range previous.Entries {
+			if record.Type == recordType {
+				records.Entries = append(records.Entries, record)
+			}
+		}
+		delete(records.Errors, recordType)
+	}
+}
+
+// swap stores current as state of target and returns the previous state
+// found by the same job settings, nil if there's none
+func (m *Monitor) swap(job Job, target string, current *state) *state {
+	current.job = jobKey(job)
+	key := current.job + "\x00" + target
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous := m.states[key]
+	m.states[key] = current
+
+	return previous
+}
+
+// prune drops states of jobs not in config anymore
+func (m *Monitor) prune(config *Config) {
+	jobs := map[string]bool{}
+	for _, job := range config.Jobs {
+		jobs[jobKey(job)] = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, s := range m.states {
+		if !jobs[s.job] {
+			delete(m.states, key)
+		}
+	}
+}
+
+// baseline logs that first results of target are known
+func (m *Monitor) baseline(job Job, target string) {
+	m.Log.Info("baseline recorded", Fields{
+		"job":    job.Name,
+		"target": target,
+	})
+}
+
+// events logs changes of target as events
+func (m *Monitor) events(job Job, target string, changes []formatter.Change) {
+	for _, change := range changes {
+		fields := Fields{
+			"job":    job.Name,
+			"type":   job.Type,
+			"target": target,
+			"change": change.Kind,
+			"host":   change.Host,
+		}
+		if change.Port > 0 {
+			fields["port"] = change.Port
+			fields["protocol"] = change.Protocol
+		}
+		if change.Old != "" {
+			fields["old"] = change.Old
+		}
+		if change.New != "" {
+			fields["new"] = change.New
+		}
+		m.Log.Warn("change detected", fields)
+	}
+}
+
+// jobKey identifies the settings of a job that affect its results
+// Changing the interval keeps the last known state
+func jobKey(job Job) string {
+	job.Interval = 0
+
+	return fmt.Sprintf("%#v", job)
+}
@@ -0,0 +1,266 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/butageek/netool/digger"
+	"github.com/butageek/netool/limiter"
+	"github.com/butageek/netool/scanner"
+)
+
+// step results of the fake scans of one run, reload is the job of a
+// config reloaded before the run
+type step struct {
+	reload  *Job
+	hosts   []scanner.Host
+	ports   []scanner.PortResult
+	records *digger.Records
+	err     error
+}
+
+func host(ip string) scanner.Host {
+	return scanner.Host{IP: net.ParseIP(ip), Method: scanner.MethodICMP}
+}
+
+func open(port int) scanner.PortResult {
+	return scanner.PortResult{Host: "10.0.0.1", Port: port, Protocol: "tcp", State: scanner.StateOpen}
+}
+
+func records(failed []string, entries ...digger.Record) *digger.Records {
+	r := &digger.Records{Domain: "example.com", Entries: entries, Errors: map[string]error{}}
+	for _, recordType := range failed {
+		r.Errors[recordType] = errors.New("timeout")
+	}
+
+	return r
+}
+
+// summary returns msg and fields telling the log entries apart, one line
+// per entry
+func summary(t *testing.T, log *bytes.Buffer) []string {
+	t.Helper()
+
+	var lines []string
+	lineScanner := bufio.NewScanner(log)
+	for lineScanner.Scan() {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(lineScanner.Bytes(), &entry); err != nil {
+			t.Fatal(err)
+		}
+		line := fmt.Sprint(entry["msg"])
+		for _, key := range []string{"change", "host", "port", "record", "old", "new", "error", "changes"} {
+			if value, ok := entry[key]; ok {
+				line += fmt.Sprintf(" %s=%v", key, value)
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+func TestRun(t *testing.T) {
+	portJob := Job{Name: "web", Type: JobPort, Targets: []string{"10.0.0.1"}, Ports: "1-1023", Interval: MinInterval}
+	otherPorts := portJob
+	otherPorts.Ports = "1-100"
+	otherInterval := portJob
+	otherInterval.Interval = 2 * MinInterval
+	netJob := Job{Name: "lan", Type: JobNet, Targets: []string{"10.0.0.0/24"}, Interval: MinInterval}
+	digJob := Job{Name: "dns", Type: JobDig, Targets: []string{"example.com"}, Interval: MinInterval}
+	a := digger.Record{Name: "example.com", Type: digger.TypeA, Value: "192.0.2.1"}
+	otherA := digger.Record{Name: "example.com", Type: digger.TypeA, Value: "192.0.2.2"}
+	mx := digger.Record{Name: "example.com", Type: digger.TypeMX, Value: "mail.example.com", Priority: 10}
+
+	tests := []struct {
+		name  string
+		job   Job
+		steps []step
+		want  []string
+	}{
+		{
+			name: "ports opened and closed",
+			job:  portJob,
+			steps: []step{
+				{ports: []scanner.PortResult{open(22)}},
+				{ports: []scanner.PortResult{open(22), open(443)}},
+				{ports: []scanner.PortResult{open(443)}},
+			},
+			want: []string{
+				"baseline recorded",
+				"job finished changes=0",
+				"change detected change=port opened host=10.0.0.1 port=443 new=open",
+				"job finished changes=1",
+				"change detected change=port closed host=10.0.0.1 port=22 old=open",
+				"job finished changes=1",
+			},
+		},
+		{
+			name: "hosts vanished",
+			job:  netJob,
+			steps: []step{
+				{hosts: []scanner.Host{host("10.0.0.1"), host("10.0.0.2")}},
+				{hosts: []scanner.Host{host("10.0.0.1")}},
+			},
+			want: []string{
+				"baseline recorded",
+				"job finished changes=0",
+				"change detected change=vanished host host=10.0.0.2",
+				"job finished changes=1",
+			},
+		},
+		{
+			name: "failed run keeps last state",
+			job:  portJob,
+			steps: []step{
+				{ports: []scanner.PortResult{open(22)}},
+				{err: errors.New("no route to host")},
+				{ports: []scanner.PortResult{open(22), open(80)}},
+			},
+			want: []string{
+				"baseline recorded",
+				"job finished changes=0",
+				"job failed error=no route to host",
+				"change detected change=port opened host=10.0.0.1 port=80 new=open",
+				"job finished changes=1",
+			},
+		},
+		{
+			name: "reload changing the job drops its state",
+			job:  portJob,
+			steps: []step{
+				{ports: []scanner.PortResult{open(22)}},
+				{reload: &otherPorts, ports: []scanner.PortResult{open(80)}},
+			},
+			want: []string{
+				"baseline recorded",
+				"job finished changes=0",
+				"baseline recorded",
+				"job finished changes=0",
+			},
+		},
+		{
+			name: "reload changing the interval keeps its state",
+			job:  portJob,
+			steps: []step{
+				{ports: []scanner.PortResult{open(22)}},
+				{reload: &otherInterval, ports: []scanner.PortResult{open(22), open(80)}},
+			},
+			want: []string{
+				"baseline recorded",
+				"job finished changes=0",
+				"change detected change=port opened host=10.0.0.1 port=80 new=open",
+				"job finished changes=1",
+			},
+		},
+		{
+			name: "records changed",
+			job:  digJob,
+			steps: []step{
+				{records: records(nil, a)},
+				{records: records(nil, otherA)},
+			},
+			want: []string{
+				"baseline recorded",
+				"job finished changes=0",
+				"change detected change=record added host=example.com new=A 192.0.2.2",
+				"change detected change=record removed host=example.com old=A 192.0.2.1",
+				"job finished changes=2",
+			},
+		},
+		{
+			name: "records of failed lookups are carried",
+			job:  digJob,
+			steps: []step{
+				{records: records(nil, a, mx)},
+				{records: records([]string{digger.TypeMX}, a)},
+				{records: records(nil, a)},
+			},
+			want: []string{
+				"baseline recorded",
+				"job finished changes=0",
+				"lookup failed record=MX error=timeout",
+				"job finished changes=0",
+				"change detected change=record removed host=example.com old=MX 10 mail.example.com",
+				"job finished changes=1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log bytes.Buffer
+			m := New(NewLogger(&log))
+			var current step
+			m.scanNet = func(context.Context, Job, *limiter.Limiter, string) ([]scanner.Host, error) {
+				return current.hosts, current.err
+			}
+			m.scanPort = func(context.Context, Job, *limiter.Limiter, []string) ([]scanner.PortResult, error) {
+				return current.ports, current.err
+			}
+			m.dig = func(context.Context, Job, string) (*digger.Records, error) {
+				return current.records, current.err
+			}
+
+			job := tt.job
+			for _, current = range tt.steps {
+				if current.reload != nil {
+					job = *current.reload
+					m.prune(&Config{Jobs: []Job{job}})
+				}
+				m.run(context.Background(), job)
+			}
+
+			if got := summary(t, &log); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("run() logged\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCarryFailed(t *testing.T) {
+	a := digger.Record{Type: digger.TypeA, Value: "192.0.2.1"}
+	mx := digger.Record{Type: digger.TypeMX, Value: "mail.example.com", Priority: 10}
+
+	tests := []struct {
+		name     string
+		records  *digger.Records
+		previous *digger.Records
+		want     *digger.Records
+	}{
+		{
+			name:     "nothing failed",
+			records:  records(nil, a),
+			previous: records(nil, a, mx),
+			want:     records(nil, a),
+		},
+		{
+			name:     "last known records are carried",
+			records:  records([]string{digger.TypeMX}, a),
+			previous: records(nil, a, mx),
+			want:     records(nil, a, mx),
+		},
+		{
+			name:     "failed in both stays failed",
+			records:  records([]string{digger.TypeMX}, a),
+			previous: records([]string{digger.TypeMX}, a),
+			want:     records([]string{digger.TypeMX}, a),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			carryFailed(tt.records, tt.previous)
+			if !reflect.DeepEqual(tt.records.Entries, tt.want.Entries) || len(tt.records.Errors) != len(tt.want.Errors) {
+				t.Errorf("carryFailed() = %+v, want %+v", tt.records, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package reference
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/gocarina/gocsv"
+)
+
+// FrequencyFile port frequency dataset shipped next to the executable
+// It ranks a curated list of commonly deployed services, other ports aren't
+// ranked
+const FrequencyFile = "port-frequency.csv"
+
+// PortFreq struct of PortFreq
+// Rank is 1 for the most commonly deployed service of the curated list, it
+// isn't measured from scans
+type PortFreq struct {
+	PortNum  int    `csv:"Port Number"`
+	Protocol string `csv:"Transport Protocol"`
+	Rank     int    `csv:"Rank"`
+}
+
+// TopPorts returns the n best ranked ports for protocol tcp or udp, best
+// ranked first. It fails when n is larger than the curated list
+func TopPorts(n int, protocol string) ([]int, error) {
+	filePath, err := filepath.Abs(filepath.Dir(os.Args[0]))
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(filePath, FrequencyFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var freqs []PortFreq
+	if err := gocsv.UnmarshalFile(f, &freqs); err != nil {
+		return nil, err
+	}
+
+	var ranked []PortFreq
+	for _, freq := range freqs {
+		if freq.Protocol == protocol {
+			ranked = append(ranked, freq)
+		}
+	}
+	if n > len(ranked) {
+		return nil, fmt.Errorf("only %d %s ports are ranked", len(ranked), protocol)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		return ranked[i].Rank < ranked[j].Rank
+	})
+
+	ports := make([]int, n)
+	for i := range ports {
+		ports[i] = ranked[i].PortNum
+	}
+
+	return ports, nil
+}
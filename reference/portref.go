@@ -35,13 +35,34 @@ func (p *PortRefArray) Init() {
 	}
 }
 
-// Find finds name of port and returns portRef object
-func (p *PortRefArray) Find(port int) *PortRef {
+// Find finds name of port for protocol tcp or udp and returns portRef object
+func (p *PortRefArray) Find(port int, protocol string) *PortRef {
 	for _, portRef := range *p {
-		if portRef.Protocol == "tcp" && portRef.PortNum == strconv.Itoa(port) {
+		if portRef.Protocol == protocol && portRef.PortNum == strconv.Itoa(port) {
 			return &portRef
 		}
 	}
 
 	return &PortRef{}
 }
+
+// NameIndex returns service names by port and protocol for fast lookups of
+// many ports, keys are made by IndexKey
+func (p *PortRefArray) NameIndex() map[string]string {
+	index := map[string]string{}
+
+	for _, portRef := range *p {
+		key := portRef.Protocol + "/" + portRef.PortNum
+		// keep the first entry like Find does
+		if _, ok := index[key]; !ok {
+			index[key] = portRef.Name
+		}
+	}
+
+	return index
+}
+
+// IndexKey returns the NameIndex key of port for protocol tcp or udp
+func IndexKey(port int, protocol string) string {
+	return protocol + "/" + strconv.Itoa(port)
+}
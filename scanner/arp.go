@@ -0,0 +1,265 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/butageek/netool/limiter"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// arpTimeout time to wait for replies after the last request is sent
+const arpTimeout = 1 * time.Second
+
+// arpRetries number of who-has requests sent to an address without reply
+const arpRetries = 2
+
+// LinkConn link-layer connection on one interface, reading and writing
+// whole Ethernet frames
+type LinkConn interface {
+	ReadFrame(buf []byte) (int, error)
+	WriteFrame(frame []byte) error
+	SetReadDeadline(t time.Time) error
+	Close() error
+}
+
+// errNotAttached returned if no interface is attached to the network
+var errNotAttached = errors.New("network is not directly attached, ARP sweep is not possible")
+
+// arpScan sweeps ips with ARP on the interface attached to their network
+func (s *Scanner) arpScan(ctx context.Context, ips []string) ([]Host, error) {
+	if len(ips) == 0 {
+		return []Host{}, nil
+	}
+
+	ip := net.ParseIP(ips[0])
+	if ip.To4() == nil {
+		return nil, errors.New("ARP sweep is only possible on IPv4 networks")
+	}
+
+	iface, srcIP, err := arpInterface(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	open := s.OpenLink
+	if open == nil {
+		open = openLink
+	}
+	conn, err := open(iface)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return arpSweep(ctx, s.Limiter, conn, iface.HardwareAddr, srcIP, ips)
+}
+
+// arpInterface finds the interface attached to network of ip and returns it
+// with its IPv4 address
+func arpInterface(ip net.IP) (*net.Interface, net.IP, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range ifaces {
+		iface := &ifaces[i]
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 || len(iface.HardwareAddr) != 6 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok || ipnet.IP.To4() == nil {
+				continue
+			}
+			if ipnet.Contains(ip) {
+				return iface, ipnet.IP.To4(), nil
+			}
+		}
+	}
+
+	return nil, nil, errNotAttached
+}
+
+// arpSweep sends ARP who-has requests for ips over conn and returns hosts
+// that replied, with their MAC address
+func arpSweep(ctx context.Context, limit *limiter.Limiter, conn LinkConn, srcMAC net.HardwareAddr, srcIP net.IP, ips []string) ([]Host, error) {
+	s := &arpSession{
+		conn:    conn,
+		srcMAC:  srcMAC,
+		srcIP:   srcIP.To4(),
+		sent:    map[string]time.Time{},
+		replies: map[string]Host{},
+	}
+
+	done := make(chan struct{})
+	wgr := sync.WaitGroup{}
+	wgr.Add(1)
+	go s.receive(done, &wgr)
+
+	var err error
+sweep:
+	for i := 0; i < arpRetries; i++ {
+		for _, ip := range ips {
+			if s.answered(ip) {
+				continue
+			}
+			if limit.Wait(ctx) != nil {
+				break sweep
+			}
+			if err = s.send(net.ParseIP(ip)); err != nil {
+				break sweep
+			}
+		}
+		s.wait(ctx, len(ips))
+	}
+
+	close(done)
+	wgr.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := []Host{}
+	for _, host := range s.replies {
+		hosts = append(hosts, host)
+	}
+
+	return hosts, nil
+}
+
+// arpSession state of an ARP sweep
+type arpSession struct {
+	conn   LinkConn
+	srcMAC net.HardwareAddr
+	srcIP  net.IP
+
+	mu      sync.Mutex
+	sent    map[string]time.Time
+	replies map[string]Host
+}
+
+// send broadcasts a who-has request for ip
+func (s *arpSession) send(ip net.IP) error {
+	eth := &layers.Ethernet{
+		SrcMAC:       s.srcMAC,
+		DstMAC:       layers.EthernetBroadcast,
+		EthernetType: layers.EthernetTypeARP,
+	}
+	arp := &layers.ARP{
+		AddrType:          layers.LinkTypeEthernet,
+		Protocol:          layers.EthernetTypeIPv4,
+		HwAddressSize:     6,
+		ProtAddressSize:   4,
+		Operation:         layers.ARPRequest,
+		SourceHwAddress:   s.srcMAC,
+		SourceProtAddress: s.srcIP,
+		DstHwAddress:      make([]byte, 6),
+		DstProtAddress:    ip.To4(),
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		FixLengths: true,
+	}
+	if err := gopacket.SerializeLayers(buf, opts, eth, arp); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.sent[ip.String()] = time.Now()
+	s.mu.Unlock()
+
+	return s.conn.WriteFrame(buf.Bytes())
+}
+
+// receive reads ARP replies until done is closed
+func (s *arpSession) receive(done <-chan struct{}, wgr *sync.WaitGroup) {
+	defer wgr.Done()
+
+	buf := make([]byte, 1514)
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		s.conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		n, err := s.conn.ReadFrame(buf)
+		if err != nil {
+			continue
+		}
+
+		packet := gopacket.NewPacket(buf[:n], layers.LayerTypeEthernet, gopacket.NoCopy)
+		arpLayer := packet.Layer(layers.LayerTypeARP)
+		if arpLayer == nil {
+			continue
+		}
+		arp := arpLayer.(*layers.ARP)
+		// skip requests and our own frames
+		if arp.Operation != layers.ARPReply || bytes.Equal(arp.SourceHwAddress, s.srcMAC) {
+			continue
+		}
+
+		ip := net.IP(arp.SourceProtAddress).String()
+		mac := net.HardwareAddr(append([]byte(nil), arp.SourceHwAddress...))
+
+		s.mu.Lock()
+		if sent, ok := s.sent[ip]; ok {
+			if _, ok := s.replies[ip]; !ok {
+				s.replies[ip] = Host{
+					IP:     net.ParseIP(ip),
+					MAC:    mac,
+					Vendor: LookupVendor(mac),
+					RTT:    time.Since(sent),
+					Method: MethodARP,
+				}
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// answered reports whether ip has replied
+func (s *arpSession) answered(ip string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.replies[ip]
+
+	return ok
+}
+
+// wait waits for replies until all addresses replied, arpTimeout passed or
+// ctx is done
+func (s *arpSession) wait(ctx context.Context, numIPs int) {
+	deadline := time.Now().Add(arpTimeout)
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		numReplies := len(s.replies)
+		s.mu.Unlock()
+		if numReplies >= numIPs {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
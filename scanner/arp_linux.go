@@ -0,0 +1,66 @@
+package scanner
+
+import (
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// packetConn LinkConn over a Linux AF_PACKET socket bound to an interface
+type packetConn struct {
+	f *os.File
+}
+
+// openLink opens an AF_PACKET socket receiving ARP frames on iface
+func openLink(iface *net.Interface) (LinkConn, error) {
+	proto := htons(syscall.ETH_P_ARP)
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW, int(proto))
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+
+	addr := &syscall.SockaddrLinklayer{
+		Protocol: proto,
+		Ifindex:  iface.Index,
+	}
+	if err := syscall.Bind(fd, addr); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+	// non-blocking sockets are handled by the runtime poller, which makes
+	// read deadlines work
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("setnonblock", err)
+	}
+
+	return &packetConn{f: os.NewFile(uintptr(fd), "packet:"+iface.Name)}, nil
+}
+
+// ReadFrame reads a frame into buf
+func (c *packetConn) ReadFrame(buf []byte) (int, error) {
+	return c.f.Read(buf)
+}
+
+// WriteFrame sends a frame out of the bound interface
+func (c *packetConn) WriteFrame(frame []byte) error {
+	_, err := c.f.Write(frame)
+
+	return err
+}
+
+// SetReadDeadline sets deadline of ReadFrame
+func (c *packetConn) SetReadDeadline(t time.Time) error {
+	return c.f.SetReadDeadline(t)
+}
+
+// Close closes the socket
+func (c *packetConn) Close() error {
+	return c.f.Close()
+}
+
+// htons converts a short from host to network byte order
+func htons(i uint16) uint16 {
+	return i<<8 | i>>8
+}
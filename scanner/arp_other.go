@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package scanner
+
+import (
+	"errors"
+	"net"
+)
+
+// openLink is only implemented on Linux
+func openLink(iface *net.Interface) (LinkConn, error) {
+	return nil, errors.New("ARP sweep is only supported on Linux")
+}
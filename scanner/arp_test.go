@@ -0,0 +1,195 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// fakeLink link answering who-has requests for the addresses in macs
+type fakeLink struct {
+	macs     map[string]net.HardwareAddr
+	writeErr error
+
+	mu       sync.Mutex
+	deadline time.Time
+	frames   chan []byte
+	requests int
+}
+
+func newFakeLink(macs map[string]net.HardwareAddr) *fakeLink {
+	return &fakeLink{
+		macs:   macs,
+		frames: make(chan []byte, 64),
+	}
+}
+
+func (l *fakeLink) ReadFrame(buf []byte) (int, error) {
+	l.mu.Lock()
+	deadline := l.deadline
+	l.mu.Unlock()
+
+	select {
+	case frame := <-l.frames:
+		return copy(buf, frame), nil
+	case <-time.After(time.Until(deadline)):
+		return 0, errors.New("timeout")
+	}
+}
+
+func (l *fakeLink) WriteFrame(frame []byte) error {
+	if l.writeErr != nil {
+		return l.writeErr
+	}
+
+	packet := gopacket.NewPacket(frame, layers.LayerTypeEthernet, gopacket.Default)
+	request := packet.Layer(layers.LayerTypeARP).(*layers.ARP)
+	l.mu.Lock()
+	l.requests++
+	l.mu.Unlock()
+
+	mac, ok := l.macs[net.IP(request.DstProtAddress).String()]
+	if !ok {
+		return nil
+	}
+	eth := &layers.Ethernet{
+		SrcMAC:       mac,
+		DstMAC:       request.SourceHwAddress,
+		EthernetType: layers.EthernetTypeARP,
+	}
+	reply := &layers.ARP{
+		AddrType:          layers.LinkTypeEthernet,
+		Protocol:          layers.EthernetTypeIPv4,
+		HwAddressSize:     6,
+		ProtAddressSize:   4,
+		Operation:         layers.ARPReply,
+		SourceHwAddress:   mac,
+		SourceProtAddress: request.DstProtAddress,
+		DstHwAddress:      request.SourceHwAddress,
+		DstProtAddress:    request.SourceProtAddress,
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, eth, reply); err != nil {
+		return err
+	}
+	l.frames <- buf.Bytes()
+
+	return nil
+}
+
+func (l *fakeLink) SetReadDeadline(t time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.deadline = t
+
+	return nil
+}
+
+func (l *fakeLink) Close() error {
+	return nil
+}
+
+func TestARPSweep(t *testing.T) {
+	srcMAC, _ := net.ParseMAC("02:00:00:00:00:01")
+	srcIP := net.ParseIP("10.0.0.254")
+	mac1, _ := net.ParseMAC("00:00:0c:00:00:01")
+	mac3, _ := net.ParseMAC("00:00:0c:00:00:03")
+
+	tests := []struct {
+		name     string
+		macs     map[string]net.HardwareAddr
+		writeErr error
+		ips      []string
+		want     map[string]net.HardwareAddr
+		requests int
+		wantErr  bool
+	}{
+		{
+			name:     "some addresses answer",
+			macs:     map[string]net.HardwareAddr{"10.0.0.1": mac1, "10.0.0.3": mac3},
+			ips:      []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+			want:     map[string]net.HardwareAddr{"10.0.0.1": mac1, "10.0.0.3": mac3},
+			requests: 3 + 1,
+		},
+		{
+			name:     "all addresses answer",
+			macs:     map[string]net.HardwareAddr{"10.0.0.1": mac1, "10.0.0.3": mac3},
+			ips:      []string{"10.0.0.1", "10.0.0.3"},
+			want:     map[string]net.HardwareAddr{"10.0.0.1": mac1, "10.0.0.3": mac3},
+			requests: 2,
+		},
+		{
+			name:     "no address answers",
+			macs:     map[string]net.HardwareAddr{},
+			ips:      []string{"10.0.0.2"},
+			want:     map[string]net.HardwareAddr{},
+			requests: arpRetries,
+		},
+		{
+			name:     "write fails",
+			macs:     map[string]net.HardwareAddr{"10.0.0.1": mac1},
+			writeErr: errors.New("link down"),
+			ips:      []string{"10.0.0.1"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link := newFakeLink(tt.macs)
+			link.writeErr = tt.writeErr
+
+			hosts, err := arpSweep(context.Background(), nil, link, srcMAC, srcIP, tt.ips)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("arpSweep() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got := map[string]net.HardwareAddr{}
+			for _, host := range hosts {
+				got[host.IP.String()] = host.MAC
+				if host.Method != MethodARP {
+					t.Errorf("host %s found by %q, want %q", host.IP, host.Method, MethodARP)
+				}
+				if host.Vendor == "" {
+					t.Errorf("host %s has no vendor", host.IP)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("arpSweep() = %v, want %v", got, tt.want)
+			}
+			if link.requests != tt.requests {
+				t.Errorf("arpSweep() sent %d requests, want %d", link.requests, tt.requests)
+			}
+		})
+	}
+}
+
+func TestARPSweepCanceled(t *testing.T) {
+	srcMAC, _ := net.ParseMAC("02:00:00:00:00:01")
+	link := newFakeLink(map[string]net.HardwareAddr{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	hosts, err := arpSweep(ctx, nil, link, srcMAC, net.ParseIP("10.0.0.254"), []string{"10.0.0.1", "10.0.0.2"})
+	if err != nil {
+		t.Fatalf("arpSweep() error = %v", err)
+	}
+	if len(hosts) != 0 || link.requests != 0 {
+		t.Errorf("arpSweep() found %d hosts with %d requests after cancel, want none", len(hosts), link.requests)
+	}
+	if elapsed := time.Since(start); elapsed > arpTimeout {
+		t.Errorf("arpSweep() took %v after cancel", elapsed)
+	}
+}
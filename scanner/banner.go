@@ -0,0 +1,73 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bannerTimeout time to wait for a service to send its banner
+const bannerTimeout = 2 * time.Second
+
+// maxBannerLen number of bytes read from a service
+const maxBannerLen = 512
+
+// DefaultNudge request sent to services that don't talk first
+var DefaultNudge = []byte("HEAD / HTTP/1.0\r\n\r\n")
+
+// readBanner reads the first bytes the service on conn sends
+// If the service stays silent, nudge is sent to make it talk
+func readBanner(conn net.Conn, nudge []byte) string {
+	buf := make([]byte, maxBannerLen)
+
+	conn.SetReadDeadline(time.Now().Add(bannerTimeout))
+	n, err := conn.Read(buf)
+	if n == 0 && len(nudge) > 0 {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			conn.SetWriteDeadline(time.Now().Add(bannerTimeout))
+			if _, err := conn.Write(nudge); err == nil {
+				conn.SetReadDeadline(time.Now().Add(bannerTimeout))
+				n, _ = conn.Read(buf)
+			}
+		}
+	}
+
+	return SanitizeBanner(buf[:n])
+}
+
+// grabBanners connects to open TCP ports in results and fills in banners
+func (s *Scanner) grabBanners(ctx context.Context, results []PortResult) {
+	open := openTCPPorts(results)
+
+	runPool(ctx, s.Limiter, len(open), func(i int) {
+		result := open[i]
+		hostPort := net.JoinHostPort(result.Host, strconv.Itoa(result.Port))
+		conn, err := net.DialTimeout("tcp", hostPort, bannerTimeout)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		result.Banner = readBanner(conn, s.Nudge)
+	})
+}
+
+// SanitizeBanner makes raw bytes sent by a service printable on one line
+// Line breaks and tabs become spaces, other unprintable bytes become dots
+func SanitizeBanner(raw []byte) string {
+	var b strings.Builder
+
+	for _, c := range raw {
+		switch {
+		case c == '\r' || c == '\n' || c == '\t':
+			b.WriteByte(' ')
+		case c < 0x20 || c > 0x7e:
+			b.WriteByte('.')
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
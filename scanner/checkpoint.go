@@ -0,0 +1,337 @@
+package scanner
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// checkpointInterval time between saves of a checkpoint while scanning
+const checkpointInterval = 10 * time.Second
+
+// Checkpoint progress of a scan saved to Path, so an interrupted scan can
+// be resumed. Args identify the scan, targets and ports already probed are
+// skipped when it's resumed and results found so far are kept
+type Checkpoint struct {
+	Path string
+	Args []string
+
+	mu        sync.Mutex
+	doneHosts map[string]bool
+	donePorts map[string]map[int]bool
+	hosts     map[string]Host
+	ports     map[string]PortResult
+	closed    int
+	filtered  int
+	complete  bool
+}
+
+// checkpointFile on-disk format of a checkpoint
+// Ports done are kept as port lists by host, eg. 1-1000,1002
+type checkpointFile struct {
+	Args      []string          `json:"args"`
+	Complete  bool              `json:"complete"`
+	DoneHosts []string          `json:"done_hosts,omitempty"`
+	DonePorts map[string]string `json:"done_ports,omitempty"`
+	Hosts     []Host            `json:"hosts,omitempty"`
+	Ports     []PortResult      `json:"ports,omitempty"`
+	Closed    int               `json:"closed,omitempty"`
+	Filtered  int               `json:"filtered,omitempty"`
+}
+
+// NewCheckpoint returns an empty checkpoint of the scan identified by args
+func NewCheckpoint(path string, args []string) *Checkpoint {
+	return &Checkpoint{
+		Path:      path,
+		Args:      args,
+		doneHosts: map[string]bool{},
+		donePorts: map[string]map[int]bool{},
+		hosts:     map[string]Host{},
+		ports:     map[string]PortResult{},
+	}
+}
+
+// LoadCheckpoint reads a checkpoint saved to path
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file checkpointFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	c := NewCheckpoint(path, file.Args)
+	c.complete = file.Complete
+	c.closed = file.Closed
+	c.filtered = file.Filtered
+	for _, ip := range file.DoneHosts {
+		c.doneHosts[ip] = true
+	}
+	for host, portStr := range file.DonePorts {
+		ports, err := ParsePorts(portStr)
+		if err != nil {
+			return nil, err
+		}
+		c.donePorts[host] = map[int]bool{}
+		for _, port := range ports {
+			c.donePorts[host][port] = true
+		}
+	}
+	for _, host := range file.Hosts {
+		c.hosts[host.IP.String()] = host
+	}
+	for _, result := range file.Ports {
+		c.ports[portKey(result)] = result
+	}
+
+	return c, nil
+}
+
+// Complete reports whether the scan ran to its end
+func (c *Checkpoint) Complete() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.complete
+}
+
+// Save writes the checkpoint to Path, replacing the previous one at once
+func (c *Checkpoint) Save() error {
+	c.mu.Lock()
+	file := checkpointFile{
+		Args:      c.Args,
+		Complete:  c.complete,
+		DonePorts: map[string]string{},
+		Closed:    c.closed,
+		Filtered:  c.filtered,
+	}
+	for ip := range c.doneHosts {
+		file.DoneHosts = append(file.DoneHosts, ip)
+	}
+	for host, done := range c.donePorts {
+		file.DonePorts[host] = formatPorts(done)
+	}
+	for _, host := range c.hosts {
+		file.Hosts = append(file.Hosts, host)
+	}
+	for _, result := range c.ports {
+		file.Ports = append(file.Ports, result)
+	}
+	c.mu.Unlock()
+
+	sort.Strings(file.DoneHosts)
+	sortHosts(file.Hosts)
+	sortPortResults(file.Ports)
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first, so a crash never leaves a partial one
+	tmp, err := ioutil.TempFile(filepath.Dir(c.Path), filepath.Base(c.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), c.Path)
+}
+
+// autosave saves the checkpoint every checkpointInterval until the returned
+// function is called, which saves it a last time and returns the first
+// error of all saves. complete marks the scan as ran to its end
+// It's a no-op for a nil checkpoint
+func (c *Checkpoint) autosave() func(complete bool) error {
+	if c == nil {
+		return func(bool) error { return nil }
+	}
+
+	done := make(chan struct{})
+	errChan := make(chan error, 1)
+	go func() {
+		var firstErr error
+		ticker := time.NewTicker(checkpointInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				errChan <- firstErr
+				return
+			case <-ticker.C:
+				if err := c.Save(); err != nil && firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+	}()
+
+	return func(complete bool) error {
+		close(done)
+		err := <-errChan
+
+		c.mu.Lock()
+		c.complete = complete
+		c.mu.Unlock()
+		if saveErr := c.Save(); err == nil {
+			err = saveErr
+		}
+
+		return err
+	}
+}
+
+// hostDone reports whether ip was probed by a net scan
+func (c *Checkpoint) hostDone(ip string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.doneHosts[ip]
+}
+
+// addHost marks ip as probed, host is the host found alive or nil
+func (c *Checkpoint) addHost(ip string, host *Host) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.doneHosts[ip] = true
+	if host != nil {
+		c.hosts[ip] = *host
+	}
+}
+
+// foundHosts returns hosts found alive by all runs of the scan
+func (c *Checkpoint) foundHosts() []Host {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hosts := []Host{}
+	for _, host := range c.hosts {
+		hosts = append(hosts, host)
+	}
+
+	return hosts
+}
+
+// portDone reports whether port of host was probed by a port scan
+func (c *Checkpoint) portDone(host string, port int) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.donePorts[host][port]
+}
+
+// pendingHosts returns hosts with ports not probed yet
+func (c *Checkpoint) pendingHosts(hosts []string, ports []int) []string {
+	if c == nil {
+		return hosts
+	}
+
+	var pending []string
+	for _, host := range hosts {
+		for _, port := range ports {
+			if !c.portDone(host, port) {
+				pending = append(pending, host)
+				break
+			}
+		}
+	}
+
+	return pending
+}
+
+// addPort marks the port of result as probed and keeps the result if it's
+// reported
+func (c *Checkpoint) addPort(result PortResult, reported bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	done, ok := c.donePorts[result.Host]
+	if !ok {
+		done = map[int]bool{}
+		c.donePorts[result.Host] = done
+	}
+	if !done[result.Port] {
+		done[result.Port] = true
+		switch result.State {
+		case StateClosed:
+			c.closed++
+		case StateFiltered, StateOpenFiltered:
+			c.filtered++
+		}
+	}
+	if reported {
+		c.ports[portKey(result)] = result
+	}
+}
+
+// foundPorts returns ports reported by all runs of the scan along with
+// numbers of closed and filtered ports
+func (c *Checkpoint) foundPorts() ([]PortResult, int, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	results := []PortResult{}
+	for _, result := range c.ports {
+		results = append(results, result)
+	}
+
+	return results, c.closed, c.filtered
+}
+
+// portKey identifies the port of a result
+func portKey(result PortResult) string {
+	return result.Protocol + "/" + result.Host + "/" + strconv.Itoa(result.Port)
+}
+
+// formatPorts formats a set of ports as port list, eg. 1-1000,1002
+func formatPorts(set map[int]bool) string {
+	ports := make([]int, 0, len(set))
+	for port := range set {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+
+	var parts []string
+	for i := 0; i < len(ports); {
+		j := i
+		for j+1 < len(ports) && ports[j+1] == ports[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(ports[i]))
+		} else {
+			parts = append(parts, strconv.Itoa(ports[i])+"-"+strconv.Itoa(ports[j]))
+		}
+		i = j + 1
+	}
+
+	return strings.Join(parts, ",")
+}
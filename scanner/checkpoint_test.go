@@ -0,0 +1,203 @@
+package scanner
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// installPortReference copies the port reference next to the test binary,
+// where ScanPort loads it from
+func installPortReference(t *testing.T) {
+	t.Helper()
+
+	dst := filepath.Join(filepath.Dir(os.Args[0]), "service-names-port-numbers.csv")
+	if _, err := os.Stat(dst); err == nil {
+		return
+	}
+	data, err := ioutil.ReadFile(filepath.Join("..", "service-names-port-numbers.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(dst, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// saved saves c and loads it back like a resumed scan does
+func saved(t *testing.T, c *Checkpoint) *Checkpoint {
+	t.Helper()
+
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadCheckpoint(c.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return loaded
+}
+
+// listen returns a local TCP listener and its port
+func listen(t *testing.T) (net.Listener, int) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return l, l.Addr().(*net.TCPAddr).Port
+}
+
+func TestFormatPorts(t *testing.T) {
+	tests := []struct {
+		name  string
+		ports []int
+		want  string
+	}{
+		{name: "empty", ports: nil, want: ""},
+		{name: "single port", ports: []int{80}, want: "80"},
+		{name: "range", ports: []int{1, 2, 3}, want: "1-3"},
+		{name: "unsorted", ports: []int{443, 22, 80}, want: "22,80,443"},
+		{name: "ranges and ports", ports: []int{1, 2, 3, 5, 7, 8}, want: "1-3,5,7-8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := map[int]bool{}
+			for _, port := range tt.ports {
+				set[port] = true
+			}
+			if got := formatPorts(set); got != tt.want {
+				t.Errorf("formatPorts(%v) = %q, want %q", tt.ports, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPendingHosts(t *testing.T) {
+	c := NewCheckpoint("", nil)
+	c.addPort(PortResult{Host: "10.0.0.1", Port: 22, Protocol: "tcp", State: StateOpen}, true)
+	c.addPort(PortResult{Host: "10.0.0.1", Port: 80, Protocol: "tcp", State: StateClosed}, false)
+	c.addPort(PortResult{Host: "10.0.0.2", Port: 22, Protocol: "tcp", State: StateFiltered}, false)
+
+	hosts := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}
+	ports := []int{22, 80}
+	if got, want := c.pendingHosts(hosts, ports), []string{"10.0.0.2", "10.0.0.3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pendingHosts() = %v, want %v", got, want)
+	}
+	var none *Checkpoint
+	if got := none.pendingHosts(hosts, ports); !reflect.DeepEqual(got, hosts) {
+		t.Errorf("pendingHosts() of nil checkpoint = %v, want %v", got, hosts)
+	}
+}
+
+func TestResumeScanPort(t *testing.T) {
+	installPortReference(t)
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	doneOpen, doneOpenPort := listen(t)
+	defer doneOpen.Close()
+	pending, pendingPort := listen(t)
+	defer pending.Close()
+	closed, closedPort := listen(t)
+	closed.Close()
+
+	// the interrupted run probed two of the three ports
+	c := NewCheckpoint(filepath.Join(dir, "state.json"), []string{"port", "127.0.0.1"})
+	c.addPort(PortResult{Host: "127.0.0.1", Port: doneOpenPort, Protocol: "tcp", State: StateOpen}, true)
+	c.addPort(PortResult{Host: "127.0.0.1", Port: closedPort, Protocol: "tcp", State: StateClosed}, false)
+
+	var probed []int
+	s := &Scanner{
+		Checkpoint: saved(t, c),
+		Progress:   &Progress{},
+		OnPort:     func(result PortResult) { probed = append(probed, result.Port) },
+	}
+	ports := strconv.Itoa(doneOpenPort) + "," + strconv.Itoa(pendingPort) + "," + strconv.Itoa(closedPort)
+	results, err := s.ScanPort(context.Background(), []string{"127.0.0.1"}, ports)
+	if err != nil {
+		t.Fatalf("ScanPort() error = %v", err)
+	}
+
+	if want := []int{pendingPort}; !reflect.DeepEqual(probed, want) {
+		t.Errorf("ScanPort() probed %v, want %v", probed, want)
+	}
+	if _, total, _ := s.Progress.Counts(); total != 1 {
+		t.Errorf("ScanPort() queued %d probes, want 1", total)
+	}
+	var found []int
+	for _, result := range results {
+		found = append(found, result.Port)
+	}
+	want := []int{doneOpenPort, pendingPort}
+	if want[0] > want[1] {
+		want[0], want[1] = want[1], want[0]
+	}
+	if !reflect.DeepEqual(found, want) {
+		t.Errorf("ScanPort() found %v, want %v", found, want)
+	}
+	if stats := s.Stats(); stats.Closed != 1 {
+		t.Errorf("ScanPort() closed = %d, want 1 of the earlier run", stats.Closed)
+	}
+
+	resumed, err := LoadCheckpoint(c.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resumed.Complete() || len(resumed.pendingHosts([]string{"127.0.0.1"}, []int{doneOpenPort, pendingPort, closedPort})) != 0 {
+		t.Errorf("ScanPort() saved an incomplete checkpoint")
+	}
+}
+
+func TestResumeScanNet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l, port := listen(t)
+	defer l.Close()
+
+	// the interrupted run found 127.0.0.0 alive, 127.0.0.1 is left
+	c := NewCheckpoint(filepath.Join(dir, "state.json"), []string{"net", "127.0.0.0/31"})
+	c.addHost("127.0.0.0", &Host{IP: net.ParseIP("127.0.0.0").To4(), Method: MethodICMP})
+
+	var probed []string
+	s := &Scanner{
+		ProbeTCP:   []int{port},
+		Checkpoint: saved(t, c),
+		Progress:   &Progress{},
+		OnHost:     func(host Host) { probed = append(probed, host.IP.String()) },
+	}
+	hosts, err := s.ScanNet(context.Background(), "127.0.0.0/31")
+	if err != nil {
+		t.Fatalf("ScanNet() error = %v", err)
+	}
+
+	if want := []string{"127.0.0.1"}; !reflect.DeepEqual(probed, want) {
+		t.Errorf("ScanNet() probed %v, want %v", probed, want)
+	}
+	if _, total, _ := s.Progress.Counts(); total != 1 {
+		t.Errorf("ScanNet() queued %d probes, want 1", total)
+	}
+	var found []string
+	for _, host := range hosts {
+		found = append(found, host.IP.String())
+	}
+	if want := []string{"127.0.0.0", "127.0.0.1"}; !reflect.DeepEqual(found, want) {
+		t.Errorf("ScanNet() found %v, want %v", found, want)
+	}
+}
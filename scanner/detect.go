@@ -0,0 +1,23 @@
+package scanner
+
+import "context"
+
+// detectServices identifies services on open TCP ports in results with the
+// probes of s.Services, replacing the registered service name on a match
+func (s *Scanner) detectServices(ctx context.Context, results []PortResult) {
+	open := openTCPPorts(results)
+
+	// detection waits for the limiter before each of its connections
+	runPool(ctx, nil, len(open), func(i int) {
+		result := open[i]
+		detected, ok := s.Services.Detect(ctx, s.Limiter, result.Host, result.Port)
+		if !ok {
+			return
+		}
+		result.Service = detected.Service
+		result.Product = detected.Product
+		result.Version = detected.Version
+		result.Info = detected.Info
+		result.CPE = detected.CPE
+	})
+}
@@ -0,0 +1,121 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/butageek/netool/limiter"
+)
+
+// discoveryTimeout time to wait for a TCP discovery probe to connect
+const discoveryTimeout = 1 * time.Second
+
+// DefaultProbeTCP ports commonly open on hosts that drop ICMP
+var DefaultProbeTCP = []int{22, 80, 443, 3389}
+
+// DefaultProbeUDP ports commonly answering UDP probes
+var DefaultProbeUDP = []int{53, 161}
+
+// hostProbe probe telling whether a host is alive
+type hostProbe struct {
+	method string
+	probe  func(ip net.IP) (time.Duration, bool)
+}
+
+// hostProbes returns probes for discovering hosts: ICMP echo if p is not nil
+// and TCP and UDP probes on ProbeTCP and ProbeUDP ports, waiting for Limiter
+// before each packet sent
+func (s *Scanner) hostProbes(ctx context.Context, p *pinger) []hostProbe {
+	var probes []hostProbe
+
+	if p != nil {
+		probes = append(probes, hostProbe{
+			method: MethodICMP,
+			probe: func(ip net.IP) (time.Duration, bool) {
+				return p.ping(ctx, s.Limiter, ip)
+			},
+		})
+	}
+	for _, port := range s.ProbeTCP {
+		probes = append(probes, hostProbe{
+			method: "tcp/" + strconv.Itoa(port),
+			probe:  tcpDiscoveryProbe(ctx, s.Limiter, port),
+		})
+	}
+	for _, port := range s.ProbeUDP {
+		probes = append(probes, hostProbe{
+			method: "udp/" + strconv.Itoa(port),
+			probe:  udpDiscoveryProbe(ctx, s.Limiter, port),
+		})
+	}
+
+	return probes
+}
+
+// discoverHost runs probes against ip concurrently and returns the host
+// found by the first probe that gets a reply
+func discoverHost(ip net.IP, probes []hostProbe) (Host, bool) {
+	found := make(chan Host, len(probes))
+	wg := sync.WaitGroup{}
+
+	for _, probe := range probes {
+		wg.Add(1)
+		go func(probe hostProbe) {
+			defer wg.Done()
+			if rtt, ok := probe.probe(ip); ok {
+				found <- Host{
+					IP:     ip,
+					RTT:    rtt,
+					Method: probe.method,
+				}
+			}
+		}(probe)
+	}
+	go func() {
+		wg.Wait()
+		close(found)
+	}()
+
+	host, ok := <-found
+
+	return host, ok
+}
+
+// tcpDiscoveryProbe returns a probe connecting to the TCP port
+// Both an accepted connection and a reset prove the host is alive
+func tcpDiscoveryProbe(ctx context.Context, limit *limiter.Limiter, port int) func(ip net.IP) (time.Duration, bool) {
+	return func(ip net.IP) (time.Duration, bool) {
+		if limit.Wait(ctx) != nil {
+			return 0, false
+		}
+		hostPort := net.JoinHostPort(ip.String(), strconv.Itoa(port))
+
+		start := time.Now()
+		conn, err := net.DialTimeout("tcp", hostPort, discoveryTimeout)
+		if err == nil {
+			conn.Close()
+			return time.Since(start), true
+		}
+		if isRefused(err) {
+			return time.Since(start), true
+		}
+
+		return 0, false
+	}
+}
+
+// udpDiscoveryProbe returns a probe sending the UDP payload for the port
+// Both a reply and ICMP port unreachable prove the host is alive
+func udpDiscoveryProbe(ctx context.Context, limit *limiter.Limiter, port int) func(ip net.IP) (time.Duration, bool) {
+	return func(ip net.IP) (time.Duration, bool) {
+		state, _, rtt, err := sendUDPProbe(ctx, limit, ip.String(), port)
+		if err != nil || (state != StateOpen && state != StateClosed) {
+			return 0, false
+		}
+
+		return rtt, true
+	}
+}
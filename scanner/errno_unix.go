@@ -0,0 +1,30 @@
+//go:build !windows
+// +build !windows
+
+package scanner
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isRefused reports whether err is a refused connection or, for UDP, an ICMP
+// port unreachable
+func isRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// isReset reports whether err is a connection reset by the peer
+func isReset(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET)
+}
+
+// isHostUnreachable reports whether err is an unreachable host
+func isHostUnreachable(err error) bool {
+	return errors.Is(err, syscall.EHOSTUNREACH)
+}
+
+// isNetUnreachable reports whether err is an unreachable network
+func isNetUnreachable(err error) bool {
+	return errors.Is(err, syscall.ENETUNREACH)
+}
@@ -0,0 +1,32 @@
+package scanner
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// Winsock reports errors with its own WSAE codes, which the syscall E
+// constants don't match
+
+// isRefused reports whether err is a refused connection or, for UDP, an ICMP
+// port unreachable
+func isRefused(err error) bool {
+	return errors.Is(err, windows.WSAECONNREFUSED)
+}
+
+// isReset reports whether err is a connection reset by the peer
+// Windows also reports ICMP port unreachable on UDP sockets as a reset
+func isReset(err error) bool {
+	return errors.Is(err, windows.WSAECONNRESET)
+}
+
+// isHostUnreachable reports whether err is an unreachable host
+func isHostUnreachable(err error) bool {
+	return errors.Is(err, windows.WSAEHOSTUNREACH)
+}
+
+// isNetUnreachable reports whether err is an unreachable network
+func isNetUnreachable(err error) bool {
+	return errors.Is(err, windows.WSAENETUNREACH)
+}
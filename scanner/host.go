@@ -0,0 +1,74 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket/macs"
+	"github.com/mostlygeek/arp"
+)
+
+// discovery methods reported in Host.Method
+const (
+	MethodICMP = "icmp"
+	MethodARP  = "arp"
+)
+
+// Host host found alive by the net scanner
+type Host struct {
+	IP       net.IP
+	MAC      net.HardwareAddr
+	Vendor   string
+	Hostname string
+	RTT      time.Duration
+	Method   string
+}
+
+// EnrichHosts fills in MAC address, vendor and hostname of the hosts
+// Hosts that already carry a value keep it. Lookups run in a pool of
+// workers, so large sweeps don't flood the DNS server
+func EnrichHosts(hosts []Host) {
+	runPool(context.Background(), nil, len(hosts), func(i int) {
+		hosts[i].Enrich()
+	})
+}
+
+// Enrich looks up MAC address in local ARP table, vendor of the MAC address
+// and hostname through reverse DNS
+func (h *Host) Enrich() {
+	if h.MAC == nil {
+		// search MAC address in local ARP table for given IP address
+		mac, err := net.ParseMAC(arp.Search(h.IP.String()))
+		if err == nil {
+			h.MAC = mac
+		}
+	}
+
+	if h.Vendor == "" && len(h.MAC) >= 3 {
+		h.Vendor = LookupVendor(h.MAC)
+	}
+
+	if h.Hostname == "" {
+		names, err := net.LookupAddr(h.IP.String())
+		if err == nil && len(names) > 0 {
+			h.Hostname = strings.TrimSuffix(names[0], ".")
+		}
+	}
+}
+
+// LookupVendor looks up manufacturer based on first 3 bytes of MAC address
+func LookupVendor(mac net.HardwareAddr) string {
+	if len(mac) < 3 {
+		return ""
+	}
+
+	prefix := [3]byte{
+		mac[0],
+		mac[1],
+		mac[2],
+	}
+
+	return macs.ValidMACPrefixMap[prefix]
+}
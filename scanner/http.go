@@ -0,0 +1,44 @@
+package scanner
+
+import (
+	"context"
+	"strings"
+
+	"github.com/butageek/netool/fetcher"
+)
+
+// webPorts ports fingerprinted as web servers regardless of service name
+var webPorts = map[int]bool{
+	80: true, 81: true, 443: true, 3000: true, 5000: true, 8000: true, 8008: true,
+	8080: true, 8081: true, 8088: true, 8443: true, 8888: true, 9000: true, 9090: true,
+}
+
+// tlsPorts ports spoken to over HTTPS if no TLS report says otherwise
+var tlsPorts = map[int]bool{443: true, 8443: true}
+
+// fetchPages fingerprints web servers on open TCP ports in results
+// Ports are web ports if they're well-known ones or their service name
+// says HTTP
+func (s *Scanner) fetchPages(ctx context.Context, results []PortResult) {
+	var web []*PortResult
+	for _, result := range openTCPPorts(results) {
+		if webPorts[result.Port] || strings.Contains(result.Service, "http") {
+			web = append(web, result)
+		}
+	}
+	myFetcher := &fetcher.Fetcher{
+		MaxRedirects: s.MaxRedirects,
+		Limiter:      s.Limiter,
+	}
+
+	// the fetcher waits for the limiter before each request it sends
+	runPool(ctx, nil, len(web), func(i int) {
+		result := web[i]
+		useTLS := result.TLS != nil || tlsPorts[result.Port] || strings.Contains(result.Service, "https")
+		page, err := myFetcher.Fetch(ctx, result.Host, result.Port, useTLS)
+		if err != nil {
+			return
+		}
+		result.HTTP = page
+	})
+}
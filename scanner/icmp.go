@@ -0,0 +1,213 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/butageek/netool/limiter"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// pingTimeout time to wait for an echo reply
+const pingTimeout = 1 * time.Second
+
+// pingRetries number of echo requests sent to a host before giving up
+const pingRetries = 2
+
+// pinger sends ICMP echo requests over one socket and matches the replies
+// to the requests by ID and sequence number
+type pinger struct {
+	conn *icmp.PacketConn
+	// datagram is set for unprivileged ICMP sockets, where the kernel picks
+	// the ID and only hands over replies for this socket
+	datagram bool
+	id       int
+	// ICMP or ICMPv6 protocol number and message types
+	proto     int
+	echoType  icmp.Type
+	replyType icmp.Type
+
+	mu      sync.Mutex
+	seq     int
+	waiters map[int]*echoWaiter
+}
+
+// echoWaiter request waiting for its echo reply
+type echoWaiter struct {
+	ip    net.IP
+	reply chan struct{}
+}
+
+// newPinger opens an ICMP socket, or an ICMPv6 socket if ipv6 is set,
+// preferring unprivileged datagram sockets and falling back to raw sockets
+func newPinger(isIPv6 bool) (*pinger, error) {
+	p := &pinger{
+		waiters:   map[int]*echoWaiter{},
+		proto:     ipv4.ICMPTypeEcho.Protocol(),
+		echoType:  ipv4.ICMPTypeEcho,
+		replyType: ipv4.ICMPTypeEchoReply,
+	}
+	datagramNet, rawNet, addr := "udp4", "ip4:icmp", "0.0.0.0"
+	if isIPv6 {
+		p.proto = ipv6.ICMPTypeEchoRequest.Protocol()
+		p.echoType = ipv6.ICMPTypeEchoRequest
+		p.replyType = ipv6.ICMPTypeEchoReply
+		datagramNet, rawNet, addr = "udp6", "ip6:ipv6-icmp", "::"
+	}
+
+	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
+		conn, err := icmp.ListenPacket(datagramNet, addr)
+		if err == nil {
+			p.conn = conn
+			p.datagram = true
+			p.id = conn.LocalAddr().(*net.UDPAddr).Port
+		}
+	}
+	if p.conn == nil {
+		conn, err := icmp.ListenPacket(rawNet, addr)
+		if err != nil {
+			return nil, err
+		}
+		p.conn = conn
+		p.id = os.Getpid() & 0xffff
+	}
+
+	go p.receive()
+
+	return p, nil
+}
+
+// close closes the socket, which stops the receiver
+func (p *pinger) close() {
+	p.conn.Close()
+}
+
+// ping sends echo requests to ip, waiting for limit before each, and
+// returns the round trip time of the first reply. Returns false if the host
+// didn't reply
+func (p *pinger) ping(ctx context.Context, limit *limiter.Limiter, ip net.IP) (time.Duration, bool) {
+	for i := 0; i < pingRetries; i++ {
+		if limit.Wait(ctx) != nil {
+			return 0, false
+		}
+		rtt, err := p.echo(ip)
+		if err != nil {
+			return 0, false
+		}
+		if rtt > 0 {
+			return rtt, true
+		}
+	}
+
+	return 0, false
+}
+
+// echo sends one echo request and waits for the reply
+// returns zero duration on timeout
+func (p *pinger) echo(ip net.IP) (time.Duration, error) {
+	waiter := &echoWaiter{
+		ip:    ip,
+		reply: make(chan struct{}, 1),
+	}
+
+	p.mu.Lock()
+	p.seq = (p.seq + 1) & 0xffff
+	seq := p.seq
+	p.waiters[seq] = waiter
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		delete(p.waiters, seq)
+		p.mu.Unlock()
+	}()
+
+	msg := icmp.Message{
+		Type: p.echoType,
+		Code: 0,
+		Body: &icmp.Echo{
+			ID:   p.id,
+			Seq:  seq,
+			Data: []byte("netool"),
+		},
+	}
+	packet, err := msg.Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var dst net.Addr = &net.IPAddr{IP: ip}
+	if p.datagram {
+		dst = &net.UDPAddr{IP: ip}
+	}
+
+	start := time.Now()
+	if _, err := p.conn.WriteTo(packet, dst); err != nil {
+		return 0, err
+	}
+
+	timer := time.NewTimer(pingTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-waiter.reply:
+		return time.Since(start), nil
+	case <-timer.C:
+		return 0, nil
+	}
+}
+
+// receive reads echo replies and hands them to waiting requests until the
+// socket is closed
+func (p *pinger) receive() {
+	buf := make([]byte, 1500)
+
+	for {
+		n, addr, err := p.conn.ReadFrom(buf)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				continue
+			}
+			return
+		}
+
+		msg, err := icmp.ParseMessage(p.proto, buf[:n])
+		if err != nil || msg.Type != p.replyType {
+			continue
+		}
+		echo, ok := msg.Body.(*icmp.Echo)
+		if !ok || (!p.datagram && echo.ID != p.id) {
+			continue
+		}
+
+		p.mu.Lock()
+		waiter, ok := p.waiters[echo.Seq]
+		p.mu.Unlock()
+		if !ok || !waiter.ip.Equal(addrIP(addr)) {
+			continue
+		}
+
+		select {
+		case waiter.reply <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// addrIP returns IP of a socket address
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+
+	return nil
+}
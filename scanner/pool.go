@@ -0,0 +1,64 @@
+package scanner
+
+import (
+	"context"
+	"sync"
+
+	"github.com/butageek/netool/limiter"
+)
+
+// numWorkers connections or lookups run at once by a worker pool, like the
+// port scanner does
+const numWorkers = 100
+
+// runPool calls fn for each index from 0 to n-1 with a pool of numWorkers
+// workers, waiting for limit before each call if it's set
+// Indexes left are skipped once ctx is done
+func runPool(ctx context.Context, limit *limiter.Limiter, n int, fn func(i int)) {
+	jobChan := make(chan int)
+	wg := sync.WaitGroup{}
+
+	workers := numWorkers
+	if n < workers {
+		workers = n
+	}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobChan {
+				if limit.Wait(ctx) != nil {
+					continue
+				}
+				fn(i)
+			}
+		}()
+	}
+
+	// stop dispatching once ctx is done
+dispatch:
+	for i := 0; i < n; i++ {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case jobChan <- i:
+		}
+	}
+	close(jobChan)
+
+	wg.Wait()
+}
+
+// openTCPPorts returns pointers to results of open TCP ports, the ports
+// connected to by enrichment phases
+func openTCPPorts(results []PortResult) []*PortResult {
+	var open []*PortResult
+
+	for i := range results {
+		if results[i].Protocol == "tcp" && results[i].State == StateOpen {
+			open = append(open, &results[i])
+		}
+	}
+
+	return open
+}
@@ -0,0 +1,44 @@
+package scanner
+
+import "sync"
+
+// Progress counts probes of a running scan, it's safe for concurrent use
+// Total grows as the scanner queues work, Hits are hosts found alive or
+// ports found open
+type Progress struct {
+	mu    sync.Mutex
+	total int
+	done  int
+	hits  int
+}
+
+// Counts returns numbers of probes done, probes in total and hits so far
+func (p *Progress) Counts() (done, total, hits int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.done, p.total, p.hits
+}
+
+// addTotal adds n probes to the total
+func (p *Progress) addTotal(n int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.total += n
+}
+
+// addDone counts n probes as done, hits of which found something
+func (p *Progress) addDone(n, hits int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done += n
+	p.hits += hits
+}
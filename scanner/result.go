@@ -0,0 +1,58 @@
+package scanner
+
+import (
+	"time"
+
+	"github.com/butageek/netool/fetcher"
+	"github.com/butageek/netool/inspector"
+)
+
+// PortState state of a scanned port
+type PortState string
+
+// port states reported by the port scanner
+const (
+	StateOpen         PortState = "open"
+	StateClosed       PortState = "closed"
+	StateFiltered     PortState = "filtered"
+	StateOpenFiltered PortState = "open|filtered"
+	StateError        PortState = "error"
+)
+
+// reasons for port states
+const (
+	ReasonSynAck      = "syn-ack"
+	ReasonConnRefused = "conn-refused"
+	ReasonReset       = "reset"
+	ReasonNoResponse  = "no-response"
+	ReasonHostUnreach = "host-unreach"
+	ReasonNetUnreach  = "net-unreach"
+	ReasonUDPResponse = "udp-response"
+	ReasonPortUnreach = "port-unreach"
+)
+
+// PortResult result of scanning a single port
+// Reason tells what the state is based on, it's the error message for
+// ports in error state
+// Banner is what the service sent first, sanitized to one printable line
+// Product, Version, Info and CPE are set if service detection identified the
+// service
+// TLS is the certificate report of ports speaking TLS if TLS inspection
+// is enabled
+// HTTP is the fingerprint of web servers if HTTP fingerprinting is enabled
+type PortResult struct {
+	Host     string
+	Port     int
+	Protocol string
+	State    PortState
+	Reason   string
+	Service  string
+	Latency  time.Duration
+	Banner   string
+	Product  string
+	Version  string
+	Info     string
+	CPE      string
+	TLS      *inspector.Report
+	HTTP     *fetcher.Page
+}
@@ -0,0 +1,130 @@
+package scanner
+
+import (
+	"sync"
+	"time"
+)
+
+// timeouts used if the scanner doesn't set them
+// DefaultInitialRTTTimeout is the timeout of probes to a host before its RTT
+// is known
+const (
+	DefaultInitialRTTTimeout = 500 * time.Millisecond
+	DefaultMinRTTTimeout     = 100 * time.Millisecond
+	DefaultMaxRTTTimeout     = 10 * time.Second
+)
+
+// tcpRetries number of times a TCP connect is tried before the port is
+// considered filtered
+const tcpRetries = 2
+
+// rttEstimator estimates the connect timeout of a host from measured round
+// trip times like TCP does, see RFC 6298
+// Only TCP connect scans adapt their timeouts
+type rttEstimator struct {
+	mu       sync.Mutex
+	srtt     time.Duration
+	rttvar   time.Duration
+	measured bool
+	initial  time.Duration
+	min, max time.Duration
+}
+
+// timeout returns the current probe timeout, SRTT plus four times RTTVAR
+// bounded by min and max
+func (e *rttEstimator) timeout() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	timeout := e.initial
+	if e.measured {
+		timeout = e.srtt + 4*e.rttvar
+	}
+	if timeout < e.min {
+		timeout = e.min
+	}
+	if timeout > e.max {
+		timeout = e.max
+	}
+
+	return timeout
+}
+
+// backoff returns the timeout of a retry after a probe timed out after
+// timeout, twice the timeout bounded by max, see RFC 6298 section 5.5
+// The estimate itself isn't backed off, as concurrent probes of the host
+// share it and a lost probe doesn't tell the host got slower
+func (e *rttEstimator) backoff(timeout time.Duration) time.Duration {
+	timeout *= 2
+	if timeout > e.max {
+		timeout = e.max
+	}
+
+	return timeout
+}
+
+// update adds a round trip time sample
+func (e *rttEstimator) update(rtt time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.measured {
+		e.srtt = rtt
+		e.rttvar = rtt / 2
+		e.measured = true
+		return
+	}
+
+	delta := e.srtt - rtt
+	if delta < 0 {
+		delta = -delta
+	}
+	e.rttvar = (3*e.rttvar + delta) / 4
+	e.srtt = (7*e.srtt + rtt) / 8
+}
+
+// rttTable RTT estimators by host
+type rttTable struct {
+	mu       sync.Mutex
+	hosts    map[string]*rttEstimator
+	initial  time.Duration
+	min, max time.Duration
+}
+
+// newRTTTable returns a table of estimators starting at initial timeout
+// and bounded by min and max, zero timeouts are replaced by defaults
+func newRTTTable(initial, min, max time.Duration) *rttTable {
+	if initial <= 0 {
+		initial = DefaultInitialRTTTimeout
+	}
+	if min <= 0 {
+		min = DefaultMinRTTTimeout
+	}
+	if max <= 0 {
+		max = DefaultMaxRTTTimeout
+	}
+	if max < min {
+		max = min
+	}
+
+	return &rttTable{
+		hosts:   map[string]*rttEstimator{},
+		initial: initial,
+		min:     min,
+		max:     max,
+	}
+}
+
+// get returns the estimator of host
+func (t *rttTable) get(host string) *rttEstimator {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.hosts[host]
+	if !ok {
+		e = &rttEstimator{initial: t.initial, min: t.min, max: t.max}
+		t.hosts[host] = e
+	}
+
+	return e
+}
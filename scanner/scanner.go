@@ -2,58 +2,207 @@ package scanner
 
 import (
 	"bytes"
+	"context"
 	"errors"
-	"fmt"
-	"log"
 	"net"
-	"os/exec"
-	"runtime"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/butageek/netool/formatter"
+	"github.com/butageek/netool/limiter"
 	"github.com/butageek/netool/reference"
+	"github.com/butageek/netool/service"
+	"github.com/butageek/netool/target"
 	"github.com/butageek/netool/validator"
 )
 
 // Scanner struct of Scanner
-type Scanner struct{}
+// UDP switches the port scanner from TCP connect to UDP probes
+// SYN switches TCP scans to half-open SYN probes, falling back to connect
+// probes without raw socket privileges
+// ARP switches net scans to ARP sweeps of directly attached networks
+// OpenLink opens the link-layer connection for ARP sweeps, a raw socket on
+// the interface is used if it's nil
+// ProbeTCP and ProbeUDP are ports probed besides ICMP echo by net scans
+// Exclude lists addresses that are never probed
+// Banner makes port scans read what open TCP ports send first, sending Nudge
+// to ports that stay silent
+// Services identifies services on open TCP ports if it's set
+// TLS makes port scans inspect certificates of open TCP ports
+// HTTP makes port scans fingerprint web servers, following up to
+// MaxRedirects redirects
+// ShowClosed and ShowFiltered make port scans report closed and filtered
+// ports besides open ones
+// Limiter limits the rate of probes of all scans if it's set
+// InitialRTTTimeout is the connect timeout of a host until its round trip
+// time is measured, MinRTTTimeout and MaxRTTTimeout bound the timeout adapted
+// to round trip times of each host. Defaults are used if they're zero. They
+// only apply to TCP connect scans, UDP and SYN probes wait fixed times
+// Checkpoint saves progress of scans if it's set, targets and ports it has
+// marked as done are skipped
+// Progress counts probes of scans if it's set
+// OnHost and OnPort are called with each host found alive and each port
+// reported once they're enriched, as soon as they're found if possible.
+// Ports are passed to OnPort after the scan if services, TLS or HTTP are
+// looked into. They're never called concurrently
+type Scanner struct {
+	UDP               bool
+	SYN               bool
+	ARP               bool
+	OpenLink          func(iface *net.Interface) (LinkConn, error)
+	ProbeTCP          []int
+	ProbeUDP          []int
+	Exclude           *target.Exclusions
+	Banner            bool
+	Nudge             []byte
+	Services          *service.DB
+	TLS               bool
+	HTTP              bool
+	MaxRedirects      int
+	ShowClosed        bool
+	ShowFiltered      bool
+	Limiter           *limiter.Limiter
+	InitialRTTTimeout time.Duration
+	MinRTTTimeout     time.Duration
+	MaxRTTTimeout     time.Duration
+	Checkpoint        *Checkpoint
+	Progress          *Progress
+	OnHost            func(host Host)
+	OnPort            func(result PortResult)
+
+	stats Stats
+	rtt   *rttTable
+	names map[string]string
+}
+
+// Stats statistics of the last scan
+// Targets is number of addresses or hosts scanned, Skipped is number of
+// excluded ones
+// Closed, Filtered and Errors are numbers of ports found in these states by
+// port scans, whether reported or not
+type Stats struct {
+	Targets  int
+	Skipped  int
+	Closed   int
+	Filtered int
+	Errors   int
+}
+
+// Stats returns statistics of the last scan
+func (s *Scanner) Stats() Stats {
+	return s.stats
+}
 
-//ScanNet scans network for hosts that are alive
-func (s *Scanner) ScanNet(cidr string) error {
+// ScanNet scans network for hosts that are alive and returns them
+// with MAC address, vendor and hostname filled in where known
+// If ctx is done before the scan completes, hosts found so far are returned
+// along with ctx.Err()
+func (s *Scanner) ScanNet(ctx context.Context, cidr string) ([]Host, error) {
 	// parse IP addresses for given cidr
-	ips, _ := getIPs(cidr)
+	ips, err := target.ExpandCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	// remove excluded addresses before any probe is sent
+	s.stats = Stats{}
+	ips, s.stats.Skipped = s.Exclude.Filter(ips)
+	s.stats.Targets = len(ips)
+
+	// skip addresses probed before the scan was interrupted
+	var pending []string
+	for _, ip := range ips {
+		if !s.Checkpoint.hostDone(ip) {
+			pending = append(pending, ip)
+		}
+	}
+	saveCheckpoint := s.Checkpoint.autosave()
+	s.Progress.addTotal(len(pending))
+
+	// sweep attached networks with ARP if requested, ping otherwise
+	var hostsAlive []Host
+	if s.ARP {
+		hostsAlive, err = s.arpScan(ctx, pending)
+		EnrichHosts(hostsAlive)
+		// replies of a sweep cut short can't tell hosts are down
+		if err == nil && ctx.Err() == nil {
+			s.checkpointSweep(pending, hostsAlive)
+			s.Progress.addDone(len(pending), len(hostsAlive))
+		}
+		if s.OnHost != nil {
+			for _, host := range hostsAlive {
+				s.OnHost(host)
+			}
+		}
+	} else {
+		hostsAlive, err = s.probeScan(ctx, pending)
+	}
+	if saveErr := saveCheckpoint(err == nil && ctx.Err() == nil); err == nil {
+		err = saveErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	// hosts were enriched before they were saved
+	if s.Checkpoint != nil {
+		hostsAlive = s.Checkpoint.foundHosts()
+	}
+	sortHosts(hostsAlive)
+
+	return hostsAlive, ctx.Err()
+}
+
+// probeScan probes ips with a pool of workers sharing one ICMP socket,
+// adding TCP and UDP probes if configured
+func (s *Scanner) probeScan(ctx context.Context, ips []string) ([]Host, error) {
+	// nothing left to probe, eg. all addresses excluded or done already
+	if len(ips) == 0 {
+		return []Host{}, nil
+	}
+
+	// open ICMP socket shared by all Scanners, it's optional if there are
+	// other probes
+	p, err := newPinger(net.ParseIP(ips[0]).To4() == nil)
+	if err != nil {
+		if len(s.ProbeTCP) == 0 && len(s.ProbeUDP) == 0 {
+			return nil, err
+		}
+		p = nil
+	} else {
+		defer p.close()
+	}
+	probes := s.hostProbes(ctx, p)
+
 	// init channels
 	jobChan := make(chan string, len(ips))
-	resultChan := make(chan string, 10)
+	resultChan := make(chan Host, 10)
 
 	// init WaitGroups
 	// wgs for Scanner, wgr for Receiver
 	wgs := sync.WaitGroup{}
 	wgr := sync.WaitGroup{}
 
-	fmt.Println()
-	log.Printf("Scanning net %s\n", cidr)
-	fmt.Println()
-
 	// set concurrency limit for Scanner
 	numScanners := 100
 	wgs.Add(numScanners)
 	for i := 1; i <= numScanners; i++ {
-		go netScanner(jobChan, resultChan, &wgs)
+		go s.netScanner(ctx, probes, jobChan, resultChan, &wgs)
 	}
 
-	hostsAlive := []net.IP{}
+	hostsAlive := []Host{}
 	// set one Receiver
 	wgr.Add(1)
-	go netReceiver(resultChan, &hostsAlive, &wgr)
+	go s.netReceiver(resultChan, &hostsAlive, &wgr)
 
-	// init jobChan using parsed IPs
+	// init jobChan using parsed IPs, stop dispatching once ctx is done
+dispatch:
 	for _, ip := range ips {
-		jobChan <- ip
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case jobChan <- ip:
+		}
 	}
 	close(jobChan)
 
@@ -61,130 +210,215 @@ func (s *Scanner) ScanNet(cidr string) error {
 	close(resultChan)
 	wgr.Wait()
 
-	sortIPs(&hostsAlive)
-
-	if len(hostsAlive) > 0 {
-		formatter := &formatter.Formatter{
-			Header:          []string{"Host", "Status", "MAC Address", "Manufacturer"},
-			Border:          false,
-			Separator:       " ",
-			ColumnSeparator: " ",
-		}
-		formatter.AssembleNetData(hostsAlive)
-		formatter.Print()
-	} else {
-		log.Println("No host alive found!")
-	}
-
-	return nil
+	return hostsAlive, nil
 }
 
-// getIPs parses given CIDR and return IPs in that range
-func getIPs(cidr string) ([]string, error) {
-	ip, ipnet, err := net.ParseCIDR(cidr)
-	if err != nil {
-		return nil, err
-	}
+// netScanner probes a host and appends it to resultChan enriched if it's
+// alive
+// Probed hosts are marked as done in the checkpoint and counted in progress
+// Jobs left in jobChan are skipped once ctx is done
+func (s *Scanner) netScanner(ctx context.Context, probes []hostProbe, jobChan <-chan string, resultChan chan<- Host, wgs *sync.WaitGroup) {
+	defer wgs.Done()
 
-	var ips []string
-	for ip := ip.Mask(ipnet.Mask); ipnet.Contains(ip); inc(ip) {
-		ips = append(ips, ip.String())
-	}
-	// remove network address and broadcast address
-	return ips[1 : len(ips)-1], nil
-}
+	for ip := range jobChan {
+		if ctx.Err() != nil {
+			continue
+		}
 
-// inc increases IP address by 1
-func inc(ip net.IP) {
-	for j := len(ip) - 1; j >= 0; j-- {
-		ip[j]++
-		if ip[j] > 0 {
-			break
+		host, ok := discoverHost(net.ParseIP(ip), probes)
+		// probes may have been cut short, probe the host again on resume
+		if ctx.Err() != nil && !ok {
+			continue
 		}
+		if !ok {
+			s.Checkpoint.addHost(ip, nil)
+			s.Progress.addDone(1, 0)
+			continue
+		}
+		host.Enrich()
+		s.Checkpoint.addHost(ip, &host)
+		s.Progress.addDone(1, 1)
+		resultChan <- host
 	}
 }
 
-// netScanner pings a host and appends it to resultChan if it's alive
-func netScanner(jobChan <-chan string, resultChan chan<- string, wgs *sync.WaitGroup) {
-	defer wgs.Done()
-
-	switch runtime.GOOS {
-	case "windows":
-		for ip := range jobChan {
-			out, _ := exec.Command("ping", "-n", "1", ip).Output()
-			if strings.Contains(string(out), "Destination host unreachable") {
-				continue
-			} else {
-				fmt.Printf("Found host: %s\n", ip)
-				resultChan <- ip
-			}
-		}
-	case "linux":
-		for ip := range jobChan {
-			_, err := exec.Command("ping", "-c", "1", ip).Output()
-			if err != nil {
-				continue
-			} else {
-				fmt.Printf("Found host: %s\n", ip)
-				resultChan <- ip
-			}
-		}
+// checkpointSweep marks ips of a finished sweep as done along with the
+// hosts found alive
+func (s *Scanner) checkpointSweep(ips []string, hostsAlive []Host) {
+	for _, ip := range ips {
+		s.Checkpoint.addHost(ip, nil)
+	}
+	for i := range hostsAlive {
+		s.Checkpoint.addHost(hostsAlive[i].IP.String(), &hostsAlive[i])
 	}
 }
 
-// netReceiver get IP from resultChan and appends to host IPs that are alive
-func netReceiver(resultChan <-chan string, hostsAlive *[]net.IP, wgr *sync.WaitGroup) {
+// netReceiver get hosts from resultChan and appends to hosts that are alive
+func (s *Scanner) netReceiver(resultChan <-chan Host, hostsAlive *[]Host, wgr *sync.WaitGroup) {
 	defer wgr.Done()
 
-	for ip := range resultChan {
-		*hostsAlive = append(*hostsAlive, net.ParseIP(ip))
+	for host := range resultChan {
+		*hostsAlive = append(*hostsAlive, host)
+		if s.OnHost != nil {
+			s.OnHost(host)
+		}
 	}
 }
 
-// sortIPs sorts IPs
-func sortIPs(ips *[]net.IP) {
-	sort.Slice(*ips, func(i, j int) bool {
-		return bytes.Compare((*ips)[i], (*ips)[j]) < 0
+// sortHosts sorts hosts by IP
+func sortHosts(hosts []Host) {
+	sort.Slice(hosts, func(i, j int) bool {
+		return bytes.Compare(hosts[i].IP.To16(), hosts[j].IP.To16()) < 0
 	})
 }
 
-// ScanPort scans open ports for the host
-func (s *Scanner) ScanPort(host, port string) error {
+// ScanPort scans open ports for the hosts and returns the ports found open
+// sorted by host and port. All hosts share the same pool of workers
+// If ctx is done before the scan completes, ports found so far are returned
+// along with ctx.Err()
+func (s *Scanner) ScanPort(ctx context.Context, hosts []string, port string) ([]PortResult, error) {
 	// init port reference object
 	portRefArray := reference.PortRefArray{}
 	portRefArray.Init()
 
 	// parse ports on the given port argument
-	ports := parsePorts(port)
+	ports, err := ParsePorts(port)
+	if err != nil {
+		return nil, err
+	}
+	// remove excluded hosts before any probe is sent
+	s.stats = Stats{}
+	s.rtt = newRTTTable(s.InitialRTTTimeout, s.MinRTTTimeout, s.MaxRTTTimeout)
+	s.names = portRefArray.NameIndex()
+	hosts, s.stats.Skipped = s.Exclude.Filter(hosts)
+	s.stats.Targets = len(hosts)
+	saveCheckpoint := s.Checkpoint.autosave()
+
+	// use half-open SYN probes if requested and permitted
+	var openedPorts []PortResult
+	if s.SYN && !s.UDP && SYNAvailable() {
+		var synResults []PortResult
+		var fallbackHosts []string
+		// hosts are scanned by SYN probes as a whole, partly done ones again
+		synResults, fallbackHosts, err = synScan(ctx, s.Limiter, s.Progress, s.Checkpoint.pendingHosts(hosts, ports), ports)
+		if err != nil {
+			saveCheckpoint(false)
+			return nil, err
+		}
+		// SYN probes don't open connections to read banners from
+		if s.Banner {
+			s.grabBanners(ctx, synResults)
+		}
+		// unanswered probes of a scan cut short aren't known to be filtered
+		cutShort := ctx.Err() != nil
+		for _, result := range synResults {
+			if s.collect(result, !cutShort) {
+				openedPorts = append(openedPorts, result)
+			}
+		}
+		// connect scan hosts SYN probes can't reach, eg. IPv6 ones
+		if len(fallbackHosts) > 0 {
+			openedPorts = append(openedPorts, s.connectScan(ctx, fallbackHosts, ports)...)
+		}
+	} else {
+		openedPorts = s.connectScan(ctx, hosts, ports)
+	}
+	if err := saveCheckpoint(ctx.Err() == nil); err != nil {
+		return nil, err
+	}
+	// report ports found by earlier runs of a resumed scan too
+	if s.Checkpoint != nil {
+		openedPorts, s.stats.Closed, s.stats.Filtered = s.Checkpoint.foundPorts()
+	}
+
+	// fill in service names from port reference
+	for i := range openedPorts {
+		openedPorts[i].Service = s.serviceName(openedPorts[i])
+	}
+	if s.Services != nil {
+		s.detectServices(ctx, openedPorts)
+	}
+	if s.TLS {
+		s.inspectTLS(ctx, openedPorts)
+	}
+	if s.HTTP {
+		s.fetchPages(ctx, openedPorts)
+	}
+	sortPortResults(openedPorts)
+	if s.enrichesPorts() && s.OnPort != nil {
+		for _, result := range openedPorts {
+			s.OnPort(result)
+		}
+	}
+
+	return openedPorts, ctx.Err()
+}
+
+// portJob port of a host to probe
+type portJob struct {
+	host string
+	port int
+}
+
+// connectScan scans ports of the hosts with a pool of workers running
+// blocking probes
+func (s *Scanner) connectScan(ctx context.Context, hosts []string, ports []int) []PortResult {
 	// init channels
-	numPorts := len(ports)
-	jobChan := make(chan int, numPorts)
-	resultChan := make(chan int, 10)
+	// jobs are generated while dispatching, many hosts times many ports
+	// don't fit in a buffer
+	numScanners := 100
+	jobChan := make(chan portJob, numScanners)
+	resultChan := make(chan PortResult, 10)
 
 	// init WaitGroups
 	// wgs for Scanner, wgr for Receiver
 	wgs := sync.WaitGroup{}
 	wgr := sync.WaitGroup{}
 
-	fmt.Println()
-	log.Printf("Scanning host %s\n", host)
-	fmt.Println()
+	// pick probe for the protocol to scan
+	probe := portProbe(s.tcpProbe)
+	if s.UDP {
+		probe = s.udpProbe
+	}
 
 	// set Scanner concurrency limit
-	numScanners := 100
 	for i := 1; i <= numScanners; i++ {
 		wgs.Add(1)
-		go portScanner(host, jobChan, resultChan, &wgs)
+		go s.portScanner(ctx, probe, jobChan, resultChan, &wgs)
 	}
 
-	openedPorts := []int{}
+	openedPorts := []PortResult{}
 	// set Receiver concurrency limit to 1
 	wgr.Add(1)
-	go portReceiver(resultChan, &openedPorts, &wgr)
+	go s.portReceiver(resultChan, &openedPorts, &wgr)
+
+	// count ports to probe for progress
+	if s.Progress != nil {
+		numJobs := 0
+		for _, host := range hosts {
+			for _, port := range ports {
+				if !s.Checkpoint.portDone(host, port) {
+					numJobs++
+				}
+			}
+		}
+		s.Progress.addTotal(numJobs)
+	}
 
-	// init jobChan using parsed ports
-	for _, port := range ports {
-		jobChan <- port
+	// init jobChan using hosts and parsed ports, stop dispatching once ctx
+	// is done
+dispatch:
+	for _, host := range hosts {
+		for _, port := range ports {
+			if s.Checkpoint.portDone(host, port) {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				break dispatch
+			case jobChan <- portJob{host: host, port: port}:
+			}
+		}
 	}
 	close(jobChan)
 
@@ -192,88 +426,235 @@ func (s *Scanner) ScanPort(host, port string) error {
 	close(resultChan)
 	wgr.Wait()
 
-	if len(openedPorts) > 0 {
-		formatter := &formatter.Formatter{
-			Header:          []string{"Port", "Protocol", "Service Name", "Description"},
-			Border:          false,
-			Separator:       " ",
-			ColumnSeparator: " ",
-		}
-		formatter.AssemblePortData(openedPorts, &portRefArray)
-		formatter.Print()
-	} else {
-		log.Println("No open ports found!")
-	}
-
-	return nil
+	return openedPorts
 }
 
-// parsePorts parses ports on port argument
+// ParsePorts parses ports on port argument
 // supports comma and dash separated ports. eg. 80,100-200
-func parsePorts(portString string) []int {
+func ParsePorts(portString string) ([]int, error) {
 	var ports []int
 	v := validator.InitValidator()
+	errFormat := errors.New("Wrong argument format: Port. Example: 80,100-200")
 
 	portsSplit := strings.Split(portString, ",")
 
 	for _, port := range portsSplit {
 		if strings.Contains(port, "-") {
 			portBounds := strings.Split(port, "-")
+			if len(portBounds) != 2 {
+				return nil, errFormat
+			}
 			portStart, err := strconv.Atoi(portBounds[0])
 			if err != nil {
-				log.Fatal(err)
+				return nil, err
 			}
 			if !validator.IsValid(v.Regex["port"], strconv.Itoa(portStart)) {
-				log.Fatal(errors.New("Wrong argument format: Port. Example: 80,100-200"))
+				return nil, errFormat
 			}
 			portEnd, err := strconv.Atoi(portBounds[1])
 			if err != nil {
-				log.Fatal(err)
+				return nil, err
 			}
 			if !validator.IsValid(v.Regex["port"], strconv.Itoa(portEnd)) {
-				log.Fatal(errors.New("Wrong argument format: Port. Example: 880,100-2000"))
+				return nil, errFormat
 			}
 			for i := portStart; i <= portEnd; i++ {
 				ports = append(ports, i)
 			}
 		} else {
 			if !validator.IsValid(v.Regex["port"], port) {
-				log.Fatal(errors.New("Wrong argument format: Port. Example: 80,100-200"))
+				return nil, errFormat
 			}
 			portNum, err := strconv.Atoi(port)
 			if err != nil {
-				log.Fatal(err)
+				return nil, err
 			}
 			ports = append(ports, portNum)
 		}
 	}
 
-	return ports
+	return ports, nil
 }
 
-// portScanner scans a port and push to resultChan if it's open
-func portScanner(host string, jobChan <-chan int, resultChan chan<- int, wgs *sync.WaitGroup) {
+// portProbe probes a port of the host, waiting for the limiter before each
+// packet sent
+// Ports not probed as ctx is done are returned without state
+type portProbe func(ctx context.Context, host string, port int) PortResult
+
+// portScanner scans a port and push the result to resultChan
+// Jobs left in jobChan are skipped once ctx is done
+func (s *Scanner) portScanner(ctx context.Context, probe portProbe, jobChan <-chan portJob, resultChan chan<- PortResult, wgs *sync.WaitGroup) {
 	defer wgs.Done()
 
-	for port := range jobChan {
-		hostIP := fmt.Sprintf("%s:%d", host, port)
+	for job := range jobChan {
+		if ctx.Err() != nil {
+			continue
+		}
 
-		conn, err := net.DialTimeout("tcp", hostIP, 500*time.Millisecond)
-		if err != nil {
+		result := probe(ctx, job.host, job.port)
+		// probe was skipped
+		if result.State == "" {
 			continue
 		}
-		defer conn.Close()
+		if result.State == StateOpen {
+			s.Progress.addDone(1, 1)
+		} else {
+			s.Progress.addDone(1, 0)
+		}
+		resultChan <- result
+	}
+}
+
+// tcpProbe tries a full TCP connect to the port, reading the banner of
+// open ports if requested
+// The connect timeout adapts to the round trip times measured for the host,
+// connects timing out are retried with doubled timeout before the port is
+// considered filtered
+func (s *Scanner) tcpProbe(ctx context.Context, host string, port int) PortResult {
+	hostPort := net.JoinHostPort(host, strconv.Itoa(port))
+	result := PortResult{
+		Host:     host,
+		Port:     port,
+		Protocol: "tcp",
+	}
+	rtt := s.rtt.get(host)
+
+	var conn net.Conn
+	var err error
+	timeout := rtt.timeout()
+	for i := 0; i < tcpRetries; i++ {
+		if i > 0 {
+			timeout = rtt.backoff(timeout)
+		}
+		if s.Limiter.Wait(ctx) != nil {
+			return PortResult{}
+		}
+		start := time.Now()
+		conn, err = net.DialTimeout("tcp", hostPort, timeout)
+		result.Latency = time.Since(start)
+		if err == nil {
+			break
+		}
+		result.State, result.Reason = dialState(err)
+		if result.State != StateFiltered || result.Reason != ReasonNoResponse {
+			break
+		}
+	}
+	if err != nil {
+		// refused connects took a full round trip too
+		if result.State == StateClosed {
+			rtt.update(result.Latency)
+		} else {
+			result.Latency = 0
+		}
+		return result
+	}
+	rtt.update(result.Latency)
+	defer conn.Close()
 
-		fmt.Printf("Found open port: %d\n", port)
-		resultChan <- port
+	result.State = StateOpen
+	result.Reason = ReasonSynAck
+	if s.Banner {
+		result.Banner = readBanner(conn, s.Nudge)
 	}
+
+	return result
 }
 
-// portReceiver receives ports from resultChan and appends to openedPorts array
-func portReceiver(resultChan <-chan int, openedPorts *[]int, wgr *sync.WaitGroup) {
+// dialState classifies a failed TCP connect
+// refused connections are closed, timeouts and unreachable hosts filtered
+func dialState(err error) (PortState, string) {
+	switch {
+	case isRefused(err):
+		return StateClosed, ReasonConnRefused
+	case isHostUnreachable(err):
+		return StateFiltered, ReasonHostUnreach
+	case isNetUnreachable(err):
+		return StateFiltered, ReasonNetUnreach
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return StateFiltered, ReasonNoResponse
+	}
+
+	return StateError, err.Error()
+}
+
+// portReceiver receives results from resultChan and appends the ones to
+// report to openedPorts array
+func (s *Scanner) portReceiver(resultChan <-chan PortResult, openedPorts *[]PortResult, wgr *sync.WaitGroup) {
 	defer wgr.Done()
 
-	for port := range resultChan {
-		*openedPorts = append(*openedPorts, port)
+	for result := range resultChan {
+		if s.collect(result, true) {
+			*openedPorts = append(*openedPorts, result)
+		}
+	}
+}
+
+// collect records result in stats and in the checkpoint and reports
+// whether it's to be reported
+// Ports in error state are not marked as done to probe them again on
+// resume, like all ports if done is false
+func (s *Scanner) collect(result PortResult, done bool) bool {
+	reported := s.record(result)
+	if done && result.State != StateError {
+		s.Checkpoint.addPort(result, reported)
+	}
+	if reported && s.OnPort != nil && !s.enrichesPorts() {
+		result.Service = s.serviceName(result)
+		s.OnPort(result)
+	}
+
+	return reported
+}
+
+// enrichesPorts reports whether ports are looked into after the scan
+func (s *Scanner) enrichesPorts() bool {
+	return s.Services != nil || s.TLS || s.HTTP
+}
+
+// serviceName returns the registered service name of the port of result
+func (s *Scanner) serviceName(result PortResult) string {
+	return s.names[reference.IndexKey(result.Port, result.Protocol)]
+}
+
+// record counts the state of a port result in stats and reports whether
+// the result is to be reported
+// Open ports are always reported, closed and filtered ones on request and
+// errors are only counted. Open|filtered ports count as filtered
+func (s *Scanner) record(result PortResult) bool {
+	switch result.State {
+	case StateClosed:
+		s.stats.Closed++
+		return s.ShowClosed
+	case StateFiltered, StateOpenFiltered:
+		s.stats.Filtered++
+		return s.ShowFiltered
+	case StateError:
+		s.stats.Errors++
+		return false
 	}
+
+	return true
+}
+
+// sortPortResults sorts port results by host and port number
+// hosts are compared by IP if both are IPs
+func sortPortResults(results []PortResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Host != results[j].Host {
+			return CompareHosts(results[i].Host, results[j].Host) < 0
+		}
+		return results[i].Port < results[j].Port
+	})
+}
+
+// CompareHosts compares hosts by IP if both are IPs, by name otherwise
+func CompareHosts(a, b string) int {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	if ipA != nil && ipB != nil {
+		return bytes.Compare(ipA.To16(), ipB.To16())
+	}
+
+	return strings.Compare(a, b)
 }
@@ -0,0 +1,368 @@
+package scanner
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/butageek/netool/limiter"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// synTimeout time to wait for replies after the last SYN is sent
+// It's fixed, replies of all probes of a round are awaited at once
+const synTimeout = 1 * time.Second
+
+// synRetries number of times a SYN is sent to a port without reply
+const synRetries = 2
+
+// errNoIPv4 returned if the host has no IPv4 address
+var errNoIPv4 = errors.New("no IPv4 address")
+
+// SYNAvailable reports whether SYN scans can be run, which requires raw
+// sockets on Linux
+func SYNAvailable() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+
+	conn, err := net.ListenPacket("ip4:tcp", "0.0.0.0")
+	if err != nil {
+		return false
+	}
+	conn.Close()
+
+	return true
+}
+
+// synScan sends SYN packets to ports of the hosts and reports the ports
+// answering with SYN/ACK as open, with RST as closed and without reply as
+// filtered. The handshake is never completed, the kernel resets the
+// connection as no socket owns it
+// Hosts without IPv4 address are returned for scanning by other means
+func synScan(ctx context.Context, limit *limiter.Limiter, progress *Progress, hosts []string, ports []int) ([]PortResult, []string, error) {
+	s := &synSession{
+		srcPort: layers.TCPPort(32768 + randUint32()%28232),
+		seq:     randUint32(),
+		targets: map[string]*synTarget{},
+		sent:    map[synKey]time.Time{},
+		replies: map[synKey]PortResult{},
+	}
+
+	var targets []*synTarget
+	var rest []string
+	for _, host := range hosts {
+		dstIP, err := resolveIPv4(host)
+		if err != nil {
+			rest = append(rest, host)
+			continue
+		}
+		// hosts resolving to an IP that's already a target share its probes
+		if t, ok := s.targets[dstIP.String()]; ok {
+			t.aliases = append(t.aliases, host)
+			continue
+		}
+		srcIP, err := localIPFor(dstIP)
+		if err != nil {
+			rest = append(rest, host)
+			continue
+		}
+		t := &synTarget{
+			host:  host,
+			dstIP: dstIP,
+			srcIP: srcIP,
+		}
+		s.targets[dstIP.String()] = t
+		targets = append(targets, t)
+	}
+	if len(targets) == 0 {
+		return nil, rest, nil
+	}
+
+	conn, err := net.ListenPacket("ip4:tcp", "0.0.0.0")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+	// replies of a burst of SYNs overflow the default receive buffer
+	if ipConn, ok := conn.(*net.IPConn); ok {
+		ipConn.SetReadBuffer(4 << 20)
+	}
+	s.conn = conn
+
+	done := make(chan struct{})
+	wgr := sync.WaitGroup{}
+	wgr.Add(1)
+	go s.receive(done, &wgr)
+
+	numProbes := len(targets) * len(ports)
+	numHosts := 0
+	for _, t := range targets {
+		numHosts += 1 + len(t.aliases)
+	}
+	progress.addTotal(numHosts * len(ports))
+	var sendErr error
+send:
+	for i := 0; i < synRetries; i++ {
+		for _, t := range targets {
+			for _, port := range ports {
+				if s.answered(t, port) {
+					continue
+				}
+				if limit.Wait(ctx) != nil {
+					break send
+				}
+				if sendErr = s.send(t, port); sendErr != nil {
+					break send
+				}
+				// retries don't add to progress
+				if i == 0 {
+					progress.addDone(1+len(t.aliases), 0)
+				}
+			}
+		}
+		s.wait(ctx, numProbes)
+	}
+
+	close(done)
+	wgr.Wait()
+	if sendErr != nil {
+		return nil, nil, sendErr
+	}
+
+	var results []PortResult
+	for key := range s.sent {
+		t := s.targets[key.ip]
+		result, ok := s.replies[key]
+		if !ok {
+			result = PortResult{
+				Host:     t.host,
+				Port:     key.port,
+				Protocol: "tcp",
+				State:    StateFiltered,
+				Reason:   ReasonNoResponse,
+			}
+		}
+		for _, host := range append([]string{t.host}, t.aliases...) {
+			result.Host = host
+			results = append(results, result)
+			if result.State == StateOpen {
+				progress.addDone(0, 1)
+			}
+		}
+	}
+
+	return results, rest, nil
+}
+
+// synTarget host scanned with SYN probes
+// Aliases are other hosts resolving to the same IP, they get copies of the
+// results of host
+type synTarget struct {
+	host    string
+	aliases []string
+	dstIP   net.IP
+	srcIP   net.IP
+}
+
+// synKey identifies a probe by target IP and port
+type synKey struct {
+	ip   string
+	port int
+}
+
+// synSession state of a SYN scan
+type synSession struct {
+	conn    net.PacketConn
+	srcPort layers.TCPPort
+	seq     uint32
+	// targets by IP
+	targets map[string]*synTarget
+
+	mu      sync.Mutex
+	sent    map[synKey]time.Time
+	replies map[synKey]PortResult
+}
+
+// send sends a SYN to the port of the target
+func (s *synSession) send(t *synTarget, port int) error {
+	ip := &layers.IPv4{
+		SrcIP:    t.srcIP,
+		DstIP:    t.dstIP,
+		Protocol: layers.IPProtocolTCP,
+	}
+	tcp := &layers.TCP{
+		SrcPort: s.srcPort,
+		DstPort: layers.TCPPort(port),
+		Seq:     s.seq,
+		SYN:     true,
+		Window:  1024,
+		Options: []layers.TCPOption{
+			{
+				OptionType:   layers.TCPOptionKindMSS,
+				OptionLength: 4,
+				OptionData:   []byte{0x05, 0xb4},
+			},
+		},
+	}
+	tcp.SetNetworkLayerForChecksum(ip)
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		ComputeChecksums: true,
+		FixLengths:       true,
+	}
+	if err := gopacket.SerializeLayers(buf, opts, tcp); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.sent[synKey{ip: t.dstIP.String(), port: port}] = time.Now()
+	s.mu.Unlock()
+
+	_, err := s.conn.WriteTo(buf.Bytes(), &net.IPAddr{IP: t.dstIP})
+
+	return err
+}
+
+// receive reads replies until done is closed
+// SYN/ACK marks a port open and RST marks it closed
+func (s *synSession) receive(done <-chan struct{}, wgr *sync.WaitGroup) {
+	defer wgr.Done()
+
+	buf := make([]byte, 1500)
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		s.conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			continue
+		}
+		ipAddr, ok := addr.(*net.IPAddr)
+		if !ok {
+			continue
+		}
+		t, ok := s.targets[ipAddr.IP.String()]
+		if !ok {
+			continue
+		}
+
+		tcp := &layers.TCP{}
+		if err := tcp.DecodeFromBytes(buf[:n], gopacket.NilDecodeFeedback); err != nil {
+			continue
+		}
+		// only replies to our SYNs acknowledge our sequence number
+		if tcp.DstPort != s.srcPort || !tcp.ACK || tcp.Ack != s.seq+1 {
+			continue
+		}
+
+		state, reason := StateClosed, ReasonReset
+		if tcp.SYN {
+			state, reason = StateOpen, ReasonSynAck
+		} else if !tcp.RST {
+			continue
+		}
+
+		key := synKey{ip: t.dstIP.String(), port: int(tcp.SrcPort)}
+		s.mu.Lock()
+		if sent, ok := s.sent[key]; ok {
+			if _, ok := s.replies[key]; !ok {
+				s.replies[key] = PortResult{
+					Host:     t.host,
+					Port:     key.port,
+					Protocol: "tcp",
+					State:    state,
+					Reason:   reason,
+					Latency:  time.Since(sent),
+				}
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// answered reports whether the port of the target has replied
+func (s *synSession) answered(t *synTarget, port int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.replies[synKey{ip: t.dstIP.String(), port: port}]
+
+	return ok
+}
+
+// wait waits for replies until all probes got replies, synTimeout passed
+// or ctx is done
+func (s *synSession) wait(ctx context.Context, numProbes int) {
+	deadline := time.Now().Add(synTimeout)
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		numReplies := len(s.replies)
+		s.mu.Unlock()
+		if numReplies >= numProbes {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// resolveIPv4 resolves host to its first IPv4 address
+func resolveIPv4(host string) (net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.To4() == nil {
+			return nil, errNoIPv4
+		}
+		return ip.To4(), nil
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4, nil
+		}
+	}
+
+	return nil, errNoIPv4
+}
+
+// localIPFor returns the local IP used to reach dst
+func localIPFor(dst net.IP) (net.IP, error) {
+	// connecting a UDP socket sends no packet but picks the route
+	conn, err := net.Dial("udp", net.JoinHostPort(dst.String(), "9"))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+// randUint32 returns a random uint32
+func randUint32() uint32 {
+	b := make([]byte, 4)
+	rand.Read(b)
+
+	return binary.BigEndian.Uint32(b)
+}
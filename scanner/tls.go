@@ -0,0 +1,22 @@
+package scanner
+
+import (
+	"context"
+
+	"github.com/butageek/netool/inspector"
+)
+
+// inspectTLS does TLS handshakes with open TCP ports in results and fills in
+// the certificate reports, ports not speaking TLS are left without report
+func (s *Scanner) inspectTLS(ctx context.Context, results []PortResult) {
+	open := openTCPPorts(results)
+	myInspector := &inspector.Inspector{}
+
+	runPool(ctx, s.Limiter, len(open), func(i int) {
+		report, err := myInspector.Inspect(open[i].Host, open[i].Port)
+		if err != nil {
+			return
+		}
+		open[i].TLS = report
+	})
+}
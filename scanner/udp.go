@@ -0,0 +1,138 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/butageek/netool/limiter"
+)
+
+// udpTimeout time to wait for a reply to a UDP probe
+// It's fixed, as services may take much longer than a round trip to reply,
+// eg. resolvers looking up a name
+const udpTimeout = 1 * time.Second
+
+// udpRetries number of times a UDP probe is sent before giving up
+const udpRetries = 2
+
+// udpPayloads payloads sent to common UDP services to provoke a reply
+// Ports not listed get an empty datagram
+var udpPayloads = map[int][]byte{
+	// DNS: standard query for NS records of the root zone
+	53: {
+		0x12, 0x34, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00,
+		0x01,
+	},
+	// NTP: version 4 client request
+	123: append([]byte{0xe3}, make([]byte, 47)...),
+	// NetBIOS: node status request for name *
+	137: append(append([]byte{
+		0x80, 0xf0, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x20, 0x43, 0x4b,
+	}, []byte("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAA")...), 0x00, 0x00, 0x21, 0x00, 0x01),
+	// SNMP: v1 get-request of sysDescr.0 with community public
+	161: {
+		0x30, 0x29, 0x02, 0x01, 0x00, 0x04, 0x06, 0x70,
+		0x75, 0x62, 0x6c, 0x69, 0x63, 0xa0, 0x1c, 0x02,
+		0x04, 0x71, 0xb4, 0xb5, 0x68, 0x02, 0x01, 0x00,
+		0x02, 0x01, 0x00, 0x30, 0x0e, 0x30, 0x0c, 0x06,
+		0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01,
+		0x00, 0x05, 0x00,
+	},
+	// SSDP: discovery of all services
+	1900: []byte("M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: 239.255.255.250:1900\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 1\r\n" +
+		"ST: ssdp:all\r\n\r\n"),
+}
+
+// udpProbe sends a protocol specific payload to the port and waits for reply
+// A reply means open, ICMP port unreachable means closed, host or network
+// unreachable means filtered and silence means open|filtered
+func (s *Scanner) udpProbe(ctx context.Context, host string, port int) PortResult {
+	result := PortResult{
+		Host:     host,
+		Port:     port,
+		Protocol: "udp",
+	}
+
+	state, reason, latency, err := sendUDPProbe(ctx, s.Limiter, host, port)
+	// probe was canceled
+	if ctx.Err() != nil {
+		return PortResult{}
+	}
+	if err != nil {
+		result.State = StateError
+		result.Reason = err.Error()
+		return result
+	}
+	result.State = state
+	result.Reason = reason
+	result.Latency = latency
+
+	return result
+}
+
+// sendUDPProbe sends the payload for the port and classifies the response,
+// waiting for limit before each datagram
+// Returns the state of the port and the reason for it
+func sendUDPProbe(ctx context.Context, limit *limiter.Limiter, host string, port int) (PortState, string, time.Duration, error) {
+	hostPort := net.JoinHostPort(host, strconv.Itoa(port))
+
+	// a connected socket gets ICMP errors reported as errors of reads and
+	// writes, eg. port unreachable as a refused connection
+	conn, err := net.Dial("udp", hostPort)
+	if err != nil {
+		return "", "", 0, err
+	}
+	defer conn.Close()
+
+	payload := udpPayloads[port]
+	buf := make([]byte, 1500)
+	for i := 0; i < udpRetries; i++ {
+		if err := limit.Wait(ctx); err != nil {
+			return "", "", 0, err
+		}
+		start := time.Now()
+		if _, err := conn.Write(payload); err != nil {
+			if state, reason, ok := unreachable(err); ok {
+				return state, reason, time.Since(start), nil
+			}
+			return "", "", 0, err
+		}
+
+		conn.SetReadDeadline(time.Now().Add(udpTimeout))
+		_, err := conn.Read(buf)
+		if err == nil {
+			return StateOpen, ReasonUDPResponse, time.Since(start), nil
+		}
+		if state, reason, ok := unreachable(err); ok {
+			return state, reason, time.Since(start), nil
+		}
+		if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+			return "", "", 0, err
+		}
+	}
+
+	return StateOpenFiltered, ReasonNoResponse, 0, nil
+}
+
+// unreachable classifies errors caused by ICMP unreachable messages
+// Port unreachable means closed, host and network unreachable filtered.
+// Returns false for other errors
+func unreachable(err error) (PortState, string, bool) {
+	switch {
+	case isRefused(err) || isReset(err):
+		return StateClosed, ReasonPortUnreach, true
+	case isHostUnreachable(err):
+		return StateFiltered, ReasonHostUnreach, true
+	case isNetUnreachable(err):
+		return StateFiltered, ReasonNetUnreach, true
+	}
+
+	return "", "", false
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// parse reads probes from a probe file
+//
+// The format follows nmap-service-probes:
+//
+//	Probe TCP <name> q|<payload>|
+//	ports <port list>
+//	match <service> m|<regex>|[flags] [p/<product>/] [v/<version>/] [i/<info>/] [cpe:/<cpe>/]
+//
+// Any character can delimit the fields, flags are i for case insensitive and
+// s for dot matching newline. Patterns are Go regular expressions
+func parse(r io.Reader, name string) ([]*Probe, error) {
+	var probes []*Probe
+	var probe *Probe
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		directive := strings.SplitN(line, " ", 2)
+		args := ""
+		if len(directive) == 2 {
+			args = strings.TrimSpace(directive[1])
+		}
+
+		var err error
+		switch directive[0] {
+		case "Probe":
+			probe, err = parseProbe(args)
+			if err == nil {
+				probes = append(probes, probe)
+			}
+		case "ports":
+			if probe == nil {
+				err = fmt.Errorf("ports before first Probe")
+				break
+			}
+			probe.Ports, err = parsePortList(args)
+		case "match":
+			if probe == nil {
+				err = fmt.Errorf("match before first Probe")
+				break
+			}
+			var m *Match
+			m, err = parseMatch(args)
+			if err == nil {
+				probe.Matches = append(probe.Matches, m)
+			}
+		default:
+			// skip directives of nmap-service-probes that aren't supported
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", name, lineNum, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return probes, nil
+}
+
+// parseProbe parses arguments of Probe directive
+func parseProbe(args string) (*Probe, error) {
+	fields := strings.SplitN(args, " ", 3)
+	if len(fields) != 3 || fields[0] != "TCP" {
+		return nil, fmt.Errorf("invalid Probe, want: Probe TCP <name> q|<payload>|")
+	}
+	if !strings.HasPrefix(fields[2], "q") {
+		return nil, fmt.Errorf("invalid Probe payload %q", fields[2])
+	}
+
+	payload, rest, err := delimited(fields[2][1:])
+	if err != nil || strings.TrimSpace(rest) != "" {
+		return nil, fmt.Errorf("invalid Probe payload %q", fields[2])
+	}
+	decoded, err := unescape(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Probe{
+		Name:    fields[1],
+		Payload: decoded,
+		Ports:   map[int]bool{},
+	}, nil
+}
+
+// parseMatch parses arguments of match directive
+func parseMatch(args string) (*Match, error) {
+	fields := strings.SplitN(args, " ", 2)
+	if len(fields) != 2 || !strings.HasPrefix(fields[1], "m") {
+		return nil, fmt.Errorf("invalid match, want: match <service> m|<regex>|")
+	}
+	m := &Match{
+		Service: fields[0],
+	}
+
+	pattern, rest, err := delimited(fields[1][1:])
+	if err != nil {
+		return nil, err
+	}
+	// flags directly follow the pattern
+	flags := ""
+	for len(rest) > 0 && (rest[0] == 'i' || rest[0] == 's') {
+		flags += rest[:1]
+		rest = rest[1:]
+	}
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+	if m.Pattern, err = regexp.Compile(pattern); err != nil {
+		return nil, err
+	}
+
+	// version info fields
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		var key string
+		switch {
+		case strings.HasPrefix(rest, "cpe:"):
+			key, rest = "cpe", rest[4:]
+		case len(rest) > 1:
+			key, rest = rest[:1], rest[1:]
+		default:
+			return nil, fmt.Errorf("invalid version info %q", rest)
+		}
+
+		var value string
+		value, rest, err = delimited(rest)
+		if err != nil {
+			return nil, err
+		}
+		// skip flags trailing the value, such as the a of cpe:/.../a
+		if i := strings.IndexByte(rest, ' '); i >= 0 {
+			rest = rest[i:]
+		} else {
+			rest = ""
+		}
+
+		switch key {
+		case "p":
+			m.Product = value
+		case "v":
+			m.Version = value
+		case "i":
+			m.Info = value
+		case "cpe":
+			m.CPE = "cpe:/" + value
+		}
+	}
+
+	return m, nil
+}
+
+// delimited returns text between the delimiter at start of s and its next
+// occurrence, along with the rest of s
+func delimited(s string) (string, string, error) {
+	if len(s) < 2 {
+		return "", "", fmt.Errorf("missing delimiter in %q", s)
+	}
+
+	end := strings.IndexByte(s[1:], s[0])
+	if end < 0 {
+		return "", "", fmt.Errorf("unterminated %q", s)
+	}
+
+	return s[1 : end+1], s[end+2:], nil
+}
+
+// unescape decodes escapes \r \n \t \0 \\ and \xHH of probe payloads
+func unescape(s string) ([]byte, error) {
+	var b []byte
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b = append(b, s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 'r':
+			b = append(b, '\r')
+		case 'n':
+			b = append(b, '\n')
+		case 't':
+			b = append(b, '\t')
+		case '0':
+			b = append(b, 0)
+		case 'x':
+			if i+3 > len(s) {
+				return nil, fmt.Errorf("invalid escape in %q", s)
+			}
+			v, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid escape in %q", s)
+			}
+			b = append(b, byte(v))
+			i += 2
+		default:
+			b = append(b, s[i])
+		}
+	}
+
+	return b, nil
+}
+
+// parsePortList parses comma separated ports and port ranges
+func parsePortList(s string) (map[int]bool, error) {
+	ports := map[int]bool{}
+
+	for _, part := range strings.Split(s, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+		start, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid ports %q", s)
+		}
+		end := start
+		if len(bounds) == 2 {
+			if end, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, fmt.Errorf("invalid ports %q", s)
+			}
+		}
+		for port := start; port <= end; port++ {
+			ports[port] = true
+		}
+	}
+
+	return ports, nil
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	input := `# netool service probes
+  # indented comment
+
+Probe TCP NULL q||
+rarity 1
+match ssh m|^SSH-([\d.]+)-OpenSSH_([\w.]+)\r?\n| p/OpenSSH/ v/$2/ i/protocol $1/ cpe:/a:openbsd:openssh:$2/a
+match shell m|^#!/bin/sh|
+
+Probe TCP GetRequest q|GET / HTTP/1.0\r\n\r\n|
+ports 80,8000-8002
+# comment between matches
+match http m|^HTTP/1\.[01] \d+.*\r\nserver: nginx/([\d.]+)|is p/nginx/ v/$1/
+`
+
+	probes, err := parse(strings.NewReader(input), "test-probes.txt")
+	if err != nil {
+		t.Fatalf("parse() error = %v", err)
+	}
+	if len(probes) != 2 {
+		t.Fatalf("parse() returned %d probes, want 2", len(probes))
+	}
+
+	null, get := probes[0], probes[1]
+	if null.Name != "NULL" || len(null.Payload) != 0 || len(null.Matches) != 2 {
+		t.Errorf("NULL probe = %+v", null)
+	}
+	if want := "GET / HTTP/1.0\r\n\r\n"; string(get.Payload) != want {
+		t.Errorf("GetRequest payload = %q, want %q", get.Payload, want)
+	}
+	if want := map[int]bool{80: true, 8000: true, 8001: true, 8002: true}; !reflect.DeepEqual(get.Ports, want) {
+		t.Errorf("GetRequest ports = %v, want %v", get.Ports, want)
+	}
+
+	ssh := null.Matches[0]
+	if ssh.Service != "ssh" || ssh.Product != "OpenSSH" || ssh.Version != "$2" || ssh.Info != "protocol $1" || ssh.CPE != "cpe:/a:openbsd:openssh:$2" {
+		t.Errorf("ssh match = %+v", ssh)
+	}
+	// # inside a pattern doesn't start a comment
+	if shell := null.Matches[1]; shell.Pattern.String() != "^#!/bin/sh" {
+		t.Errorf("shell pattern = %q", shell.Pattern)
+	}
+	if http := get.Matches[0]; !http.Pattern.MatchString("HTTP/1.1 200 OK\r\nSERVER: nginx/1.24.0") {
+		t.Errorf("http pattern %q ignores flags", http.Pattern)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "match before probe", input: "# comment\nmatch ssh m|^SSH|", want: "test:2:"},
+		{name: "ports before probe", input: "ports 22", want: "test:1:"},
+		{name: "udp probe", input: "Probe UDP DNS q||", want: "test:1:"},
+		{name: "unterminated payload", input: "Probe TCP Get q|GET /", want: "test:1:"},
+		{name: "invalid regex", input: "Probe TCP NULL q||\n\nmatch x m|(|", want: "test:3:"},
+		{name: "invalid ports", input: "Probe TCP NULL q||\nports 80,http", want: "test:2:"},
+		{name: "invalid escape", input: `Probe TCP Bin q|\xZZ|`, want: "test:1:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse(strings.NewReader(tt.input), "test")
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("parse() error = %v, want prefix %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	probes, err := parse(strings.NewReader(`Probe TCP NULL q||
+match ssh m|^SSH-([\d.]+)-OpenSSH_([\w.]+)| p/OpenSSH/ v/$2/ i/protocol $1/ cpe:/a:openbsd:openssh:$2/
+`), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		response string
+		want     *Result
+	}{
+		{
+			name:     "match",
+			response: "SSH-2.0-OpenSSH_8.9p1 Ubuntu\r\n",
+			want: &Result{
+				Service: "ssh",
+				Product: "OpenSSH",
+				Version: "8.9p1",
+				Info:    "protocol 2.0",
+				CPE:     "cpe:/a:openbsd:openssh:8.9p1",
+			},
+		},
+		{name: "no match", response: "220 ftp ready\r\n", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := probes[0].match([]byte(tt.response))
+			if ok != (tt.want != nil) || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("match(%q) = %+v, %v, want %+v", tt.response, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadShipped(t *testing.T) {
+	db, err := Load("../" + DefaultFile)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(db.Probes) == 0 {
+		t.Error("Load() found no probes")
+	}
+}
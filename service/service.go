@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/butageek/netool/limiter"
+)
+
+// DefaultFile probe file shipped next to the executable
+const DefaultFile = "service-probes.txt"
+
+// readTimeout time to wait for a response to a probe
+const readTimeout = 2 * time.Second
+
+// maxResponseLen number of bytes of a response matched against
+const maxResponseLen = 4096
+
+// Probe payload sent to a service along with matches for its responses
+// Ports are the ports the probe is tried first on
+type Probe struct {
+	Name    string
+	Payload []byte
+	Ports   map[int]bool
+	Matches []*Match
+}
+
+// Match pattern identifying a service from a probe response
+// Product, Version, Info and CPE may refer to groups of Pattern as $1..$9
+type Match struct {
+	Service string
+	Pattern *regexp.Regexp
+	Product string
+	Version string
+	Info    string
+	CPE     string
+}
+
+// Result service identified on a port
+type Result struct {
+	Service string
+	Product string
+	Version string
+	Info    string
+	CPE     string
+}
+
+// DB database of probes
+type DB struct {
+	Probes []*Probe
+}
+
+// Load loads probes from files, later files add to earlier ones
+func Load(paths ...string) (*DB, error) {
+	db := &DB{}
+
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		probes, err := parse(f, path)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		db.add(probes)
+	}
+
+	return db, nil
+}
+
+// LoadDefault loads the probe file shipped next to the executable followed
+// by extra probe files
+func LoadDefault(extra ...string) (*DB, error) {
+	dir, err := filepath.Abs(filepath.Dir(os.Args[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	return Load(append([]string{filepath.Join(dir, DefaultFile)}, extra...)...)
+}
+
+// add adds probes to db, matches of probes with a known name are appended
+// to that probe so custom files can extend the shipped probes
+func (db *DB) add(probes []*Probe) {
+	for _, probe := range probes {
+		if existing := db.probe(probe.Name); existing != nil {
+			existing.Matches = append(existing.Matches, probe.Matches...)
+			for port := range probe.Ports {
+				existing.Ports[port] = true
+			}
+			continue
+		}
+		db.Probes = append(db.Probes, probe)
+	}
+}
+
+// probe returns probe with given name or nil
+func (db *DB) probe(name string) *Probe {
+	for _, probe := range db.Probes {
+		if probe.Name == name {
+			return probe
+		}
+	}
+
+	return nil
+}
+
+// Detect identifies the service on the TCP port of host, waiting for limit
+// before each connection
+// Probes with empty payload are tried first, then probes meant for the port,
+// then the others. Returns false if nothing matched or ctx is done
+func (db *DB) Detect(ctx context.Context, limit *limiter.Limiter, host string, port int) (*Result, bool) {
+	for _, probe := range db.order(port) {
+		if limit.Wait(ctx) != nil {
+			return nil, false
+		}
+		response, err := send(host, port, probe.Payload)
+		// port doesn't accept connections anymore
+		if err != nil {
+			return nil, false
+		}
+		if len(response) == 0 {
+			continue
+		}
+		if result, ok := probe.match(response); ok {
+			return result, true
+		}
+	}
+
+	return nil, false
+}
+
+// order returns probes in the order they are tried on port
+func (db *DB) order(port int) []*Probe {
+	var null, forPort, others []*Probe
+
+	for _, probe := range db.Probes {
+		switch {
+		case len(probe.Payload) == 0:
+			null = append(null, probe)
+		case probe.Ports[port]:
+			forPort = append(forPort, probe)
+		default:
+			others = append(others, probe)
+		}
+	}
+
+	return append(append(null, forPort...), others...)
+}
+
+// match matches response against matches of the probe
+func (p *Probe) match(response []byte) (*Result, bool) {
+	for _, m := range p.Matches {
+		groups := m.Pattern.FindSubmatch(response)
+		if groups == nil {
+			continue
+		}
+
+		return &Result{
+			Service: m.Service,
+			Product: expand(m.Product, groups),
+			Version: expand(m.Version, groups),
+			Info:    expand(m.Info, groups),
+			CPE:     expand(m.CPE, groups),
+		}, true
+	}
+
+	return nil, false
+}
+
+// expand replaces $1..$9 in template with groups of a match
+func expand(template string, groups [][]byte) string {
+	if !strings.Contains(template, "$") {
+		return template
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(template); i++ {
+		if template[i] == '$' && i+1 < len(template) && template[i+1] >= '1' && template[i+1] <= '9' {
+			n, _ := strconv.Atoi(template[i+1 : i+2])
+			if n < len(groups) {
+				b.Write(groups[n])
+			}
+			i++
+			continue
+		}
+		b.WriteByte(template[i])
+	}
+
+	return b.String()
+}
+
+// send connects to the port, sends payload and returns the response
+// Only failures to connect or send are returned as error, the response ends
+// at the first read error
+func send(host string, port int, payload []byte) ([]byte, error) {
+	hostPort := net.JoinHostPort(host, strconv.Itoa(port))
+	conn, err := net.DialTimeout("tcp", hostPort, readTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if len(payload) > 0 {
+		conn.SetWriteDeadline(time.Now().Add(readTimeout))
+		if _, err := conn.Write(payload); err != nil {
+			return nil, err
+		}
+	}
+
+	// read until the service stops talking or the buffer is full
+	buf := make([]byte, maxResponseLen)
+	n := 0
+	deadline := time.Now().Add(readTimeout)
+	for n < len(buf) {
+		conn.SetReadDeadline(deadline)
+		m, err := conn.Read(buf[n:])
+		n += m
+		if err != nil {
+			break
+		}
+		// give the rest of the response a moment once data arrived
+		deadline = time.Now().Add(200 * time.Millisecond)
+	}
+
+	return buf[:n], nil
+}
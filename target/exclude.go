@@ -0,0 +1,130 @@
+package target
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Exclusions addresses that must not be scanned, given as IPs, CIDRs,
+// ranges or hostnames
+type Exclusions struct {
+	nets   []*net.IPNet
+	ranges [][2]net.IP
+	ips    map[string]bool
+	names  map[string]bool
+}
+
+// ParseExclusions parses exclusion specs
+// A spec is a CIDR, a range like 10.0.0.1-50, an IP address or a hostname
+func ParseExclusions(specs []string) (*Exclusions, error) {
+	e := &Exclusions{
+		ips:   map[string]bool{},
+		names: map[string]bool{},
+	}
+
+	for _, spec := range specs {
+		spec = strings.TrimSuffix(strings.TrimPrefix(spec, "["), "]")
+
+		switch {
+		case strings.Contains(spec, "/"):
+			_, ipnet, err := net.ParseCIDR(spec)
+			if err != nil {
+				return nil, err
+			}
+			e.nets = append(e.nets, ipnet)
+		case net.ParseIP(spec) != nil:
+			e.ips[net.ParseIP(spec).String()] = true
+		case strings.Contains(spec, "-") && net.ParseIP(strings.SplitN(spec, "-", 2)[0]) != nil:
+			start, end, err := parseRange(spec)
+			if err != nil {
+				return nil, err
+			}
+			e.ranges = append(e.ranges, [2]net.IP{start, end})
+		default:
+			if _, err := Expand(spec); err != nil {
+				return nil, fmt.Errorf("invalid exclusion %q", spec)
+			}
+			e.names[strings.ToLower(strings.TrimSuffix(spec, "."))] = true
+		}
+	}
+
+	return e, nil
+}
+
+// Len returns number of exclusion specs
+func (e *Exclusions) Len() int {
+	if e == nil {
+		return 0
+	}
+
+	return len(e.nets) + len(e.ranges) + len(e.ips) + len(e.names)
+}
+
+// Contains reports whether host is excluded
+// Hostnames are excluded by name or if any of their IPs is excluded
+func (e *Exclusions) Contains(host string) bool {
+	if e.Len() == 0 {
+		return false
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return e.containsIP(ip)
+	}
+	if e.names[strings.ToLower(strings.TrimSuffix(host, "."))] {
+		return true
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return false
+	}
+	for _, ip := range ips {
+		if e.containsIP(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Filter removes excluded hosts and returns remaining hosts along with
+// number of hosts removed
+func (e *Exclusions) Filter(hosts []string) ([]string, int) {
+	if e.Len() == 0 {
+		return hosts, 0
+	}
+
+	var kept []string
+	for _, host := range hosts {
+		if !e.Contains(host) {
+			kept = append(kept, host)
+		}
+	}
+
+	return kept, len(hosts) - len(kept)
+}
+
+// containsIP reports whether ip is excluded
+func (e *Exclusions) containsIP(ip net.IP) bool {
+	if e.ips[ip.String()] {
+		return true
+	}
+	for _, ipnet := range e.nets {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	for _, r := range e.ranges {
+		// ranges hold IPv4 addresses in 4 bytes form
+		cmpIP := ip.To16()
+		if len(r[0]) == net.IPv4len {
+			cmpIP = ip.To4()
+		}
+		if cmpIP != nil && compare(cmpIP, r[0]) >= 0 && compare(cmpIP, r[1]) <= 0 {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,83 @@
+package target
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseExclusions(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		len     int
+		wantErr bool
+	}{
+		{name: "none", specs: nil, len: 0},
+		{name: "all kinds", specs: []string{"10.0.0.0/24", "10.1.0.1-50", "10.2.0.1", "[::1]", "Router.Example.com."}, len: 5},
+		{name: "invalid cidr", specs: []string{"10.0.0.0/33"}, wantErr: true},
+		{name: "reversed range", specs: []string{"10.0.0.50-1"}, wantErr: true},
+		{name: "invalid hostname", specs: []string{"not a host"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseExclusions(tt.specs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseExclusions(%q) error = %v, wantErr %v", tt.specs, err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Len() != tt.len {
+				t.Errorf("ParseExclusions(%q).Len() = %d, want %d", tt.specs, got.Len(), tt.len)
+			}
+		})
+	}
+}
+
+func TestExclusionsContains(t *testing.T) {
+	exclude, err := ParseExclusions([]string{"10.0.0.0/24", "10.1.0.1-50", "10.2.0.1", "2001:db8::/120", "router.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{host: "10.0.0.200", want: true},
+		{host: "10.0.1.1", want: false},
+		{host: "10.1.0.1", want: true},
+		{host: "10.1.0.50", want: true},
+		{host: "10.1.0.51", want: false},
+		{host: "10.2.0.1", want: true},
+		{host: "10.2.0.2", want: false},
+		{host: "2001:db8::ff", want: true},
+		{host: "2001:db8::100", want: false},
+		{host: "ROUTER.example.com.", want: true},
+		{host: "::ffff:10.0.0.1", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := exclude.Contains(tt.host); got != tt.want {
+				t.Errorf("Contains(%q) = %v, want %v", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExclusionsFilter(t *testing.T) {
+	exclude, err := ParseExclusions([]string{"10.0.0.2-3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts, skipped := exclude.Filter([]string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"})
+	if want := []string{"10.0.0.1", "10.0.0.4"}; !reflect.DeepEqual(hosts, want) || skipped != 2 {
+		t.Errorf("Filter() = %q, %d, want %q, 2", hosts, skipped, want)
+	}
+
+	// nil exclusions exclude nothing
+	var none *Exclusions
+	if hosts, skipped := none.Filter([]string{"10.0.0.1"}); len(hosts) != 1 || skipped != 0 {
+		t.Errorf("Filter() of nil exclusions = %q, %d", hosts, skipped)
+	}
+}
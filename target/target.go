@@ -0,0 +1,213 @@
+package target
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/butageek/netool/validator"
+)
+
+// maxIPv4Hosts largest IPv4 network ExpandCIDR expands, a /16
+const maxIPv4Hosts = 1 << 16
+
+// maxIPv6Hosts largest IPv6 network ExpandCIDR expands, a /112
+const maxIPv6Hosts = 1 << 16
+
+// maxRangeHosts largest range ExpandRange expands
+const maxRangeHosts = 1 << 16
+
+// Parse expands target specs into a list of hosts without duplicates
+// A spec is a CIDR, a range like 10.0.0.1-50 or 10.0.0.1-10.0.0.50,
+// an IP address or a hostname
+func Parse(specs []string) ([]string, error) {
+	var hosts []string
+	seen := map[string]bool{}
+
+	for _, spec := range specs {
+		expanded, err := Expand(spec)
+		if err != nil {
+			return nil, err
+		}
+		for _, host := range expanded {
+			if !seen[host] {
+				seen[host] = true
+				hosts = append(hosts, host)
+			}
+		}
+	}
+
+	return hosts, nil
+}
+
+// Expand expands one target spec into hosts
+func Expand(spec string) ([]string, error) {
+	// accept bracketed IPv6 literals such as [::1]
+	spec = strings.TrimSuffix(strings.TrimPrefix(spec, "["), "]")
+
+	switch {
+	case strings.Contains(spec, "/"):
+		return ExpandCIDR(spec)
+	case net.ParseIP(spec) != nil:
+		return []string{spec}, nil
+	case strings.Contains(spec, "-") && net.ParseIP(strings.SplitN(spec, "-", 2)[0]) != nil:
+		return ExpandRange(spec)
+	}
+
+	v := validator.InitValidator()
+	if !validator.IsValid(v.Regex["hostname"], spec) {
+		return nil, fmt.Errorf("invalid target %q", spec)
+	}
+
+	return []string{spec}, nil
+}
+
+// ExpandCIDR parses given CIDR and return IPs in that range
+func ExpandCIDR(cidr string) ([]string, error) {
+	ip, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	ones, bits := ipnet.Mask.Size()
+	isIPv6 := ip.To4() == nil
+	if isIPv6 && bits-ones > 16 {
+		return nil, fmt.Errorf("IPv6 network %s is too large, max %d addresses (/112)", cidr, maxIPv6Hosts)
+	}
+	if !isIPv6 && bits-ones > 16 {
+		return nil, fmt.Errorf("network %s is too large, max %d addresses (/16)", cidr, maxIPv4Hosts)
+	}
+
+	var ips []string
+	for ip := ip.Mask(ipnet.Mask); ipnet.Contains(ip); inc(ip) {
+		ips = append(ips, ip.String())
+	}
+
+	switch {
+	// point-to-point and single host networks have no addresses to spare
+	case bits-ones <= 1:
+		return ips, nil
+	// remove subnet-router anycast address
+	case isIPv6:
+		return ips[1:], nil
+	// remove network address and broadcast address
+	default:
+		return ips[1 : len(ips)-1], nil
+	}
+}
+
+// ExpandRange parses a range of IPs and returns IPs in that range
+// The end is either a full IP or the last octet, eg. 10.0.0.1-50
+func ExpandRange(ipRange string) ([]string, error) {
+	start, end, err := parseRange(ipRange)
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []string
+	for ip := append(net.IP(nil), start...); compare(ip, end) <= 0; inc(ip) {
+		if len(ips) >= maxRangeHosts {
+			return nil, fmt.Errorf("range %s is too large, max %d addresses", ipRange, maxRangeHosts)
+		}
+		ips = append(ips, ip.String())
+		// stop at the last address instead of wrapping around
+		if ip.Equal(end) {
+			break
+		}
+	}
+
+	return ips, nil
+}
+
+// parseRange parses a range of IPs and returns its first and last IP
+// IPv4 addresses are returned in 4 bytes form
+func parseRange(ipRange string) (net.IP, net.IP, error) {
+	errFormat := fmt.Errorf("invalid range %q, eg. 10.0.0.1-50", ipRange)
+
+	bounds := strings.SplitN(ipRange, "-", 2)
+	start := net.ParseIP(bounds[0])
+	if start == nil {
+		return nil, nil, errFormat
+	}
+
+	end := net.ParseIP(bounds[1])
+	if end == nil {
+		// end is the last octet of an IPv4 address
+		start4 := start.To4()
+		last, err := strconv.Atoi(bounds[1])
+		if start4 == nil || err != nil || last < 0 || last > 255 {
+			return nil, nil, errFormat
+		}
+		end = net.IPv4(start4[0], start4[1], start4[2], byte(last))
+	}
+	if (start.To4() == nil) != (end.To4() == nil) {
+		return nil, nil, errFormat
+	}
+	if start.To4() != nil {
+		start, end = start.To4(), end.To4()
+	}
+	if compare(start, end) > 0 {
+		return nil, nil, errFormat
+	}
+
+	return start, end, nil
+}
+
+// ReadFile reads target specs from file
+// Specs are separated by whitespace or commas, # starts a comment
+func ReadFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var specs []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		specs = append(specs, fields...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(specs) == 0 {
+		return nil, errors.New("no targets in " + path)
+	}
+
+	return specs, nil
+}
+
+// inc increases IP address by 1
+func inc(ip net.IP) {
+	for j := len(ip) - 1; j >= 0; j-- {
+		ip[j]++
+		if ip[j] > 0 {
+			break
+		}
+	}
+}
+
+// compare compares two IPs of the same length
+func compare(a, b net.IP) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,106 @@
+package target
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "ip", specs: []string{"10.0.0.1"}, want: []string{"10.0.0.1"}},
+		{name: "hostname", specs: []string{"example.com"}, want: []string{"example.com"}},
+		{name: "bracketed ipv6", specs: []string{"[::1]"}, want: []string{"::1"}},
+		{name: "cidr", specs: []string{"10.0.0.0/30"}, want: []string{"10.0.0.1", "10.0.0.2"}},
+		{name: "range", specs: []string{"10.0.0.1-3"}, want: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}},
+		{
+			name:  "duplicates are dropped",
+			specs: []string{"10.0.0.2", "10.0.0.1-3", "10.0.0.0/30"},
+			want:  []string{"10.0.0.2", "10.0.0.1", "10.0.0.3"},
+		},
+		{name: "invalid target", specs: []string{"10.0.0.1", "not a host"}, wantErr: true},
+		{name: "invalid cidr", specs: []string{"10.0.0.0/33"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.specs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, wantErr %v", tt.specs, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %q, want %q", tt.specs, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandCIDR(t *testing.T) {
+	tests := []struct {
+		name    string
+		cidr    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "network and broadcast removed", cidr: "192.168.1.0/30", want: []string{"192.168.1.1", "192.168.1.2"}},
+		{name: "host bits are masked", cidr: "192.168.1.3/30", want: []string{"192.168.1.1", "192.168.1.2"}},
+		{name: "point-to-point /31", cidr: "192.168.1.0/31", want: []string{"192.168.1.0", "192.168.1.1"}},
+		{name: "single host /32", cidr: "192.168.1.7/32", want: []string{"192.168.1.7"}},
+		{name: "ipv6 anycast removed", cidr: "2001:db8::/126", want: []string{"2001:db8::1", "2001:db8::2", "2001:db8::3"}},
+		{name: "ipv6 point-to-point /127", cidr: "2001:db8::/127", want: []string{"2001:db8::", "2001:db8::1"}},
+		{name: "ipv6 single host /128", cidr: "2001:db8::5/128", want: []string{"2001:db8::5"}},
+		{name: "ipv6 too large", cidr: "2001:db8::/64", wantErr: true},
+		{name: "too large", cidr: "10.0.0.0/8", wantErr: true},
+		{name: "whole address space", cidr: "0.0.0.0/0", wantErr: true},
+		{name: "invalid", cidr: "192.168.1.0/40", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandCIDR(tt.cidr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExpandCIDR(%q) error = %v, wantErr %v", tt.cidr, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExpandCIDR(%q) = %q, want %q", tt.cidr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		ipRange string
+		want    []string
+		wantErr bool
+	}{
+		{name: "last octet", ipRange: "10.0.0.254-255", want: []string{"10.0.0.254", "10.0.0.255"}},
+		{name: "full address", ipRange: "10.0.0.255-10.0.1.1", want: []string{"10.0.0.255", "10.0.1.0", "10.0.1.1"}},
+		{name: "single address", ipRange: "10.0.0.5-5", want: []string{"10.0.0.5"}},
+		{name: "ends at last address", ipRange: "255.255.255.254-255", want: []string{"255.255.255.254", "255.255.255.255"}},
+		{name: "ipv6", ipRange: "2001:db8::1-2001:db8::2", want: []string{"2001:db8::1", "2001:db8::2"}},
+		{name: "reversed", ipRange: "10.0.0.50-10", wantErr: true},
+		{name: "reversed full address", ipRange: "10.0.1.0-10.0.0.1", wantErr: true},
+		{name: "mixed families", ipRange: "10.0.0.1-2001:db8::1", wantErr: true},
+		{name: "last octet out of range", ipRange: "10.0.0.1-256", wantErr: true},
+		{name: "ipv6 with last octet", ipRange: "2001:db8::1-5", wantErr: true},
+		{name: "too large", ipRange: "10.0.0.0-10.1.0.0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandRange(tt.ipRange)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExpandRange(%q) error = %v, wantErr %v", tt.ipRange, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExpandRange(%q) = %q, want %q", tt.ipRange, got, tt.want)
+			}
+		})
+	}
+}
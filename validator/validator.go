@@ -16,7 +16,9 @@ func InitValidator() *Validator {
 	}
 	v.Regex["domain"] = `^\w+\.\w{2,4}$`
 	v.Regex["port"] = `^\d+([,-]\d+)*$`
-	v.Regex["cidr"] = `^\d{1,3}\.\d{1,3}\.\d{1,3}.\d{1,3}\/\d{1,2}$`
+	v.Regex["cidr"] = `^\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}\/([1-9]|[12]\d|3[0-2])$`
+	v.Regex["hostname"] = `^[a-zA-Z0-9]([a-zA-Z0-9-]{0,62}\.?)*$`
+	v.Regex["cidr6"] = `^[0-9a-fA-F]{0,4}(:[0-9a-fA-F]{0,4}){2,7}\/([1-9]\d?|1[01]\d|12[0-8])$`
 
 	return &v
 }